@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// load is the shape of a run, shared by every subcommand.
+type load struct {
+	concurrency int
+	n           int
+	duration    time.Duration
+}
+
+func (l *load) register(fs *flag.FlagSet) {
+	fs.IntVar(&l.concurrency, "c", 8, "number of concurrent callers")
+	fs.IntVar(&l.n, "n", 10000, "number of operations, 0 for no limit")
+	fs.DurationVar(&l.duration, "d", 0, "stop after this long, 0 for no limit")
+}
+
+func (l *load) validate() error {
+	if l.concurrency < 1 {
+		return fmt.Errorf("-c must be at least 1")
+	}
+	if l.n <= 0 && l.duration <= 0 {
+		return fmt.Errorf("one of -n or -d is required")
+	}
+	return nil
+}
+
+type result struct {
+	ops       int
+	errors    map[string]int
+	elapsed   time.Duration
+	latencies []time.Duration // sorted
+}
+
+// bench calls op from l.concurrency goroutines until l.n calls were made, or
+// l.duration elapsed, or ctx is done.
+func bench(ctx context.Context, l load, op func(ctx context.Context) error) result {
+	if l.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.duration)
+		defer cancel()
+	}
+
+	var (
+		started atomic.Int64
+		mu      sync.Mutex
+		r       = result{errors: make(map[string]int)}
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	wg.Add(l.concurrency)
+	for i := 0; i < l.concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			var latencies []time.Duration
+			errors := make(map[string]int)
+			for ctx.Err() == nil && (l.n <= 0 || started.Add(1) <= int64(l.n)) {
+				opStart := time.Now()
+				err := op(ctx)
+				if err != nil && ctx.Err() != nil {
+					// Interrupted by the end of the run, not a failure.
+					break
+				}
+				latencies = append(latencies, time.Since(opStart))
+				if err != nil {
+					errors[err.Error()]++
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			r.latencies = append(r.latencies, latencies...)
+			for msg, n := range errors {
+				r.errors[msg] += n
+			}
+		}()
+	}
+	wg.Wait()
+	r.elapsed = time.Since(start)
+	r.ops = len(r.latencies)
+	slices.Sort(r.latencies)
+	return r
+}
+
+// percentile returns the p-th percentile (0 < p <= 1) of sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(latencies))*p+0.5) - 1
+	return latencies[min(max(i, 0), len(latencies)-1)]
+}
+
+func (r result) print(w io.Writer) {
+	failed := 0
+	for _, n := range r.errors {
+		failed += n
+	}
+	fmt.Fprintf(w, "ops         %d\n", r.ops)
+	fmt.Fprintf(w, "errors      %d\n", failed)
+	msgs := make([]string, 0, len(r.errors))
+	for msg := range r.errors {
+		msgs = append(msgs, msg)
+	}
+	sort.Strings(msgs)
+	for _, msg := range msgs {
+		fmt.Fprintf(w, "  %6d    %s\n", r.errors[msg], msg)
+	}
+	fmt.Fprintf(w, "elapsed     %v\n", r.elapsed.Round(time.Millisecond))
+	throughput := 0.0
+	if r.elapsed > 0 {
+		throughput = float64(r.ops) / r.elapsed.Seconds()
+	}
+	fmt.Fprintf(w, "throughput  %.1f ops/s\n", throughput)
+	fmt.Fprintf(w, "latency     p50=%v p90=%v p99=%v max=%v\n",
+		percentile(r.latencies, 0.50), percentile(r.latencies, 0.90),
+		percentile(r.latencies, 0.99), percentile(r.latencies, 1))
+}
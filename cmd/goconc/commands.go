@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"rhzx3519/go-concurrency/examples"
+	"rhzx3519/go-concurrency/examples/contextpattern"
+	"rhzx3519/go-concurrency/examples/mysqlpattern"
+	"rhzx3519/go-concurrency/examples/producerconsumerpattern"
+	"time"
+)
+
+// parse registers the load flags on fs and parses args.
+func parse(fs *flag.FlagSet, args []string) (load, error) {
+	var l load
+	l.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return l, err
+	}
+	if fs.NArg() > 0 {
+		return l, fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	return l, l.validate()
+}
+
+func runBridge(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("bridge", flag.ContinueOnError)
+	size := fs.Int("size", 10, "values per bridged channel")
+	l, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan interface{})
+	defer close(done)
+	chanStream := make(chan (<-chan interface{}))
+	go func() {
+		defer close(chanStream)
+		for {
+			stream := make(chan interface{}, *size)
+			for i := 0; i < *size; i++ {
+				stream <- i
+			}
+			close(stream)
+			select {
+			case chanStream <- stream:
+			case <-done:
+				return
+			}
+		}
+	}()
+	valStream := examples.Bridge(done, chanStream)
+
+	bench(ctx, l, func(ctx context.Context) error {
+		select {
+		case <-valStream:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}).print(w)
+	return nil
+}
+
+func runProducer(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("producer", flag.ContinueOnError)
+	l, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	producer := producerconsumerpattern.NewProducer()
+	producer.Run(ctx)
+	defer stop(w, producer.Component("producer"))
+	bench(ctx, l, func(ctx context.Context) error {
+		_, err := producer.Produce(ctx)
+		return err
+	}).print(w)
+	return nil
+}
+
+func runRWProducer(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("rwproducer", flag.ContinueOnError)
+	writes := fs.Float64("writes", 0.5, "fraction of operations that are writes")
+	l, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	producer := producerconsumerpattern.NewRWProducer()
+	readStream := producer.Run(ctx)
+	defer stop(w, producer.Component("rwproducer"))
+	bench(ctx, l, func(ctx context.Context) error {
+		if rand.Float64() < *writes {
+			return producer.Write(ctx)
+		}
+		select {
+		case <-readStream:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}).print(w)
+	return nil
+}
+
+// counterStore is what counter-bench needs from a backend.
+type counterStore interface {
+	AddCounter(ctx context.Context, name string) error
+	Add1(ctx context.Context, name string) error
+}
+
+type memoryCounters struct {
+	*mysqlpattern.MemoryStore
+}
+
+func (s memoryCounters) AddCounter(_ context.Context, name string) error {
+	_, err := s.MemoryStore.AddCounter(name)
+	return err
+}
+
+func (s memoryCounters) Add1(_ context.Context, name string) error {
+	_, err := s.MemoryStore.Add1(name)
+	return err
+}
+
+type mysqlCounters struct {
+	*mysqlpattern.MysqlClient
+}
+
+func (c mysqlCounters) AddCounter(ctx context.Context, name string) error {
+	_, err := c.MysqlClient.AddCounterContext(ctx, name)
+	return err
+}
+
+func (c mysqlCounters) Add1(ctx context.Context, name string) error {
+	_, err := c.MysqlClient.Add1Context(ctx, name)
+	return err
+}
+
+func runCounterBench(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("counter-bench", flag.ContinueOnError)
+	backend := fs.String("backend", "memory", "memory, or mysql (configured by DBUSER, DBPASS, DBHOST, DBPORT)")
+	counters := fs.Int("counters", 16, "number of distinct counters")
+	shards := fs.Int("shards", 32, "shards of the memory backend")
+	l, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if *counters < 1 {
+		return fmt.Errorf("-counters must be at least 1")
+	}
+
+	var store counterStore
+	switch *backend {
+	case "memory":
+		store = memoryCounters{mysqlpattern.NewMemoryStore(*shards)}
+	case "mysql":
+		client := mysqlpattern.NewMysqlClient()
+		if err := client.Run(ctx); err != nil {
+			return err
+		}
+		defer stop(w, client.Component("mysql client"))
+		store = mysqlCounters{client}
+	default:
+		return fmt.Errorf("unknown backend %q", *backend)
+	}
+
+	names := make([]string, *counters)
+	for i := range names {
+		names[i] = fmt.Sprintf("goconc-%d", i)
+		// An existing counter is fine.
+		store.AddCounter(ctx, names[i])
+	}
+	bench(ctx, l, func(ctx context.Context) error {
+		return store.Add1(ctx, names[rand.IntN(len(names))])
+	}).print(w)
+	return nil
+}
+
+func runContextDemo(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("context-demo", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 10*time.Second, "deadline of each greeting and farewell")
+	l, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	bench(ctx, l, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		if _, err := contextpattern.Greeting(ctx); err != nil {
+			return fmt.Errorf("greeting: %w", err)
+		}
+		if _, err := contextpattern.Farewell(ctx); err != nil {
+			return fmt.Errorf("farewell: %w", err)
+		}
+		return nil
+	}).print(w)
+	return nil
+}
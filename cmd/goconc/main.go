@@ -0,0 +1,98 @@
+// Command goconc runs the patterns of this module under load and reports
+// their throughput and latency percentiles.
+//
+//	goconc <command> [-c concurrency] [-n ops] [-d duration] [flags]
+//
+// Run "goconc <command> -h" for the flags of a command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"rhzx3519/go-concurrency/examples/shutdownpattern"
+	"sort"
+	"time"
+)
+
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string, w io.Writer) error
+}
+
+var commands = map[string]command{
+	"bridge":        {"read values bridged from a sequence of channels", runBridge},
+	"producer":      {"request values from a Producer loop", runProducer},
+	"rwproducer":    {"mix writes and reads on an RWProducer", runRWProducer},
+	"counter-bench": {"increment counters through a counter store", runCounterBench},
+	"context-demo":  {"generate greetings and farewells under a deadline", runContextDemo},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: goconc <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].summary)
+	}
+}
+
+// shutdownTimeout bounds how long a command waits for its components to
+// drain once the load is over.
+const shutdownTimeout = 5 * time.Second
+
+// stop shuts the components down in dependency order and reports the ones
+// that did not stop cleanly to w.
+func stop(w io.Writer, components ...shutdownpattern.Component) {
+	coordinator := shutdownpattern.NewCoordinator()
+	for _, c := range components {
+		if err := coordinator.Register(c); err != nil {
+			fmt.Fprintln(w, err)
+			return
+		}
+	}
+	// The command's context may be canceled already, by an interrupt.
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if report, err := coordinator.Shutdown(ctx); err != nil {
+		fmt.Fprint(w, report)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "goconc: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	err := cmd.run(ctx, args[1:], stdout)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "goconc %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"rhzx3519/go-concurrency/examples/mysqlpattern"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	for _, args := range [][]string{
+		{"bridge", "-n", "1000", "-size", "3"},
+		{"producer", "-n", "1000"},
+		{"rwproducer", "-n", "1000", "-c", "4"},
+		{"counter-bench", "-n", "1000", "-counters", "2"},
+	} {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 0, run(context.TODO(), args, &stdout, &stderr), stderr.String())
+		assert.Contains(t, stdout.String(), "ops         1000\n", args)
+		assert.Contains(t, stdout.String(), "errors      0\n", args)
+		assert.Contains(t, stdout.String(), "latency     p50=", args)
+	}
+}
+
+func TestRun_ContextDemo(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, run(context.TODO(), []string{"context-demo", "-n", "10", "-timeout", "10ms"}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "ops         10\n")
+	assert.Contains(t, stdout.String(), "errors      0\n")
+}
+
+func TestRun_Errors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 2, run(context.TODO(), nil, &stdout, &stderr))
+	assert.Equal(t, 2, run(context.TODO(), []string{"nope"}, &stdout, &stderr))
+	assert.Equal(t, 1, run(context.TODO(), []string{"producer", "-n", "0"}, &stdout, &stderr))
+	assert.Equal(t, 1, run(context.TODO(), []string{"counter-bench", "-backend", "nope"}, &stdout, &stderr))
+}
+
+func TestBench_Duration(t *testing.T) {
+	r := bench(context.TODO(), load{concurrency: 2, duration: 20 * time.Millisecond}, func(ctx context.Context) error {
+		select {
+		case <-time.After(time.Millisecond):
+			return errors.New("slow")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	assert.Greater(t, r.ops, 0)
+	assert.Equal(t, r.ops, r.errors["slow"])
+	assert.GreaterOrEqual(t, r.elapsed, 20*time.Millisecond)
+}
+
+func TestPercentile(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i))
+	}
+	assert.Equal(t, time.Duration(50), percentile(latencies, 0.5))
+	assert.Equal(t, time.Duration(99), percentile(latencies, 0.99))
+	assert.Equal(t, time.Duration(100), percentile(latencies, 1))
+	assert.Equal(t, time.Duration(0), percentile(nil, 0.5))
+}
+
+func TestMysqlCounters_Errors(t *testing.T) {
+	// A client that is shut down fails every call, and the bench must see it.
+	client := mysqlpattern.NewMysqlClient()
+	assert.NoError(t, client.Shutdown(context.TODO()))
+	store := mysqlCounters{client}
+	assert.ErrorIs(t, store.AddCounter(context.TODO(), "reading"), mysqlpattern.ErrClosed)
+	assert.ErrorIs(t, store.Add1(context.TODO(), "reading"), mysqlpattern.ErrClosed)
+}
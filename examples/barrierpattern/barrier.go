@@ -0,0 +1,121 @@
+package barrierpattern
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrBrokenBarrier = errors.New("broken barrier")
+
+// CyclicBarrier makes a fixed number of parties wait for each other, then
+// releases them together and resets for the next round. The optional action
+// runs once per round, by the last party to arrive, before the others are
+// released.
+//
+// If a party gives up (its context is done) the barrier is broken: every
+// waiting and future party gets ErrBrokenBarrier until Reset is called.
+type CyclicBarrier struct {
+	parties int
+	action  func()
+	mu      sync.Mutex
+	count   int // parties still expected in this round
+	gen     *generation
+}
+
+type generation struct {
+	done   chan struct{}
+	broken bool
+}
+
+func NewCyclicBarrier(parties int, action func()) *CyclicBarrier {
+	if parties <= 0 {
+		panic("barrierpattern: parties must be positive")
+	}
+	return &CyclicBarrier{
+		parties: parties,
+		action:  action,
+		count:   parties,
+		gen:     &generation{done: make(chan struct{})},
+	}
+}
+
+// Await waits until every party has called Await. It returns the arrival
+// index of the caller: parties-1 for the first to arrive, 0 for the last.
+func (b *CyclicBarrier) Await(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	gen := b.gen
+	if gen.broken {
+		b.mu.Unlock()
+		return 0, ErrBrokenBarrier
+	}
+	b.count--
+	index := b.count
+	if index == 0 {
+		if b.action != nil {
+			b.action()
+		}
+		b.next()
+		b.mu.Unlock()
+		return 0, nil
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-gen.done:
+		if gen.broken {
+			return index, ErrBrokenBarrier
+		}
+		return index, nil
+	case <-ctx.Done():
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.gen == gen && !gen.broken {
+			b.breakBarrier()
+			return index, ctx.Err()
+		}
+		// The round completed or broke meanwhile.
+		if gen.broken {
+			return index, ErrBrokenBarrier
+		}
+		return index, nil
+	}
+}
+
+// Reset breaks the current round, if any party is waiting, and starts a new
+// one.
+func (b *CyclicBarrier) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.breakBarrier()
+	b.next()
+}
+
+// Waiting reports the number of parties waiting in the current round.
+func (b *CyclicBarrier) Waiting() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.parties - b.count
+}
+
+func (b *CyclicBarrier) IsBroken() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gen.broken
+}
+
+func (b *CyclicBarrier) next() {
+	if !b.gen.broken {
+		close(b.gen.done)
+	}
+	b.count = b.parties
+	b.gen = &generation{done: make(chan struct{})}
+}
+
+func (b *CyclicBarrier) breakBarrier() {
+	if b.gen.broken {
+		return
+	}
+	b.gen.broken = true
+	close(b.gen.done)
+}
@@ -0,0 +1,65 @@
+package barrierpattern
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCyclicBarrier_Rounds(t *testing.T) {
+	const parties, rounds = 5, 3
+	var mu sync.Mutex
+	arrivals, actions := 0, 0
+	barrier := NewCyclicBarrier(parties, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		// Every party of the round has arrived before the action runs.
+		assert.Equal(t, (actions+1)*parties, arrivals)
+		actions++
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < parties; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				mu.Lock()
+				arrivals++
+				mu.Unlock()
+				_, err := barrier.Await(context.TODO())
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, rounds, actions)
+}
+
+func TestCyclicBarrier_Broken(t *testing.T) {
+	barrier := NewCyclicBarrier(3, nil)
+
+	errStream := make(chan error)
+	go func() {
+		_, err := barrier.Await(context.TODO())
+		errStream <- err
+	}()
+	assert.Eventually(t, func() bool { return barrier.Waiting() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err := barrier.Await(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, <-errStream, ErrBrokenBarrier)
+	assert.True(t, barrier.IsBroken())
+
+	_, err = barrier.Await(context.TODO())
+	assert.ErrorIs(t, err, ErrBrokenBarrier)
+
+	barrier.Reset()
+	assert.False(t, barrier.IsBroken())
+	assert.Equal(t, 0, barrier.Waiting())
+}
@@ -0,0 +1,58 @@
+package barrierpattern
+
+import (
+	"context"
+	"sync"
+)
+
+// CountDownLatch lets goroutines wait until a count reaches zero. Unlike a
+// WaitGroup it can be waited on with a context, and a latch of one makes a
+// start gate: every goroutine waits, one CountDown releases them all.
+type CountDownLatch struct {
+	mu    sync.Mutex
+	count int
+	done  chan struct{}
+}
+
+func NewCountDownLatch(count int) *CountDownLatch {
+	l := &CountDownLatch{count: count, done: make(chan struct{})}
+	if count <= 0 {
+		l.count = 0
+		close(l.done)
+	}
+	return l
+}
+
+// CountDown decrements the count, releasing the waiters when it reaches
+// zero. Extra calls are no-ops.
+func (l *CountDownLatch) CountDown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.count == 0 {
+		return
+	}
+	l.count--
+	if l.count == 0 {
+		close(l.done)
+	}
+}
+
+func (l *CountDownLatch) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Done is closed when the count reaches zero.
+func (l *CountDownLatch) Done() <-chan struct{} {
+	return l.done
+}
+
+func (l *CountDownLatch) Wait(ctx context.Context) error {
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
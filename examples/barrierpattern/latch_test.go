@@ -0,0 +1,49 @@
+package barrierpattern
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountDownLatch_StartGate(t *testing.T) {
+	start := NewCountDownLatch(1)
+	var started atomic.Int32
+	var wg sync.WaitGroup
+	const N = 100
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, start.Wait(context.TODO()))
+			started.Add(1)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), started.Load())
+
+	start.CountDown()
+	wg.Wait()
+	assert.Equal(t, int32(N), started.Load())
+}
+
+func TestCountDownLatch_Wait(t *testing.T) {
+	latch := NewCountDownLatch(2)
+	latch.CountDown()
+	assert.Equal(t, 1, latch.Count())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, latch.Wait(ctx), context.DeadlineExceeded)
+
+	latch.CountDown()
+	latch.CountDown()
+	assert.Equal(t, 0, latch.Count())
+	assert.NoError(t, latch.Wait(context.TODO()))
+
+	assert.NoError(t, NewCountDownLatch(0).Wait(context.TODO()))
+}
@@ -0,0 +1,103 @@
+package barrierpattern
+
+import (
+	"context"
+	"sync"
+)
+
+// Phaser is a reusable barrier whose number of parties can change between
+// phases. A phase advances once every registered party has arrived.
+type Phaser struct {
+	mu      sync.Mutex
+	parties int
+	arrived int
+	phase   int
+	advance chan struct{} // closed when the current phase advances
+}
+
+func NewPhaser(parties int) *Phaser {
+	return &Phaser{parties: parties, advance: make(chan struct{})}
+}
+
+// Register adds a party and returns the current phase.
+func (p *Phaser) Register() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.parties++
+	return p.phase
+}
+
+// Arrive records the arrival of a party without waiting for the others and
+// returns the phase it arrived at.
+func (p *Phaser) Arrive() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase := p.phase
+	p.arrived++
+	p.tryAdvance()
+	return phase
+}
+
+// ArriveAndDeregister arrives and removes the party for the next phases.
+func (p *Phaser) ArriveAndDeregister() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	phase := p.phase
+	p.parties--
+	p.tryAdvance()
+	return phase
+}
+
+// ArriveAndAwaitAdvance arrives and waits for the others, returning the new
+// phase. If ctx is done first the arrival still counts.
+func (p *Phaser) ArriveAndAwaitAdvance(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	phase, advance := p.phase, p.advance
+	p.arrived++
+	p.tryAdvance()
+	p.mu.Unlock()
+	return p.await(ctx, phase, advance)
+}
+
+// AwaitAdvance waits for the given phase to advance and returns the new
+// phase. It returns right away if phase is not the current one.
+func (p *Phaser) AwaitAdvance(ctx context.Context, phase int) (int, error) {
+	p.mu.Lock()
+	current, advance := p.phase, p.advance
+	p.mu.Unlock()
+	if phase != current {
+		return current, nil
+	}
+	return p.await(ctx, phase, advance)
+}
+
+func (p *Phaser) Phase() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.phase
+}
+
+func (p *Phaser) Parties() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.parties
+}
+
+func (p *Phaser) await(ctx context.Context, phase int, advance <-chan struct{}) (int, error) {
+	select {
+	case <-advance:
+		return phase + 1, nil
+	case <-ctx.Done():
+		return phase, ctx.Err()
+	}
+}
+
+func (p *Phaser) tryAdvance() {
+	if p.arrived < p.parties {
+		return
+	}
+	p.phase++
+	p.arrived = 0
+	close(p.advance)
+	p.advance = make(chan struct{})
+}
@@ -0,0 +1,59 @@
+package barrierpattern
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaser_DynamicParties(t *testing.T) {
+	phaser := NewPhaser(1) // the test itself
+
+	var wg sync.WaitGroup
+	const N = 10
+	for i := 0; i < N; i++ {
+		phaser.Register()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Worker i takes part in i+1 phases.
+			for p := 0; p <= i; p++ {
+				if p == i {
+					phaser.ArriveAndDeregister()
+					return
+				}
+				_, err := phaser.ArriveAndAwaitAdvance(context.TODO())
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+
+	for p := 0; p < N; p++ {
+		// Worker p may or may not have deregistered yet.
+		assert.GreaterOrEqual(t, phaser.Parties(), N-p)
+		phase, err := phaser.ArriveAndAwaitAdvance(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, p+1, phase)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, phaser.Parties())
+}
+
+func TestPhaser_AwaitAdvance(t *testing.T) {
+	phaser := NewPhaser(2)
+	assert.Equal(t, 0, phaser.Arrive())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err := phaser.AwaitAdvance(ctx, 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	phaser.Arrive()
+	phase, err := phaser.AwaitAdvance(context.TODO(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, phase)
+	assert.Equal(t, 1, phaser.Phase())
+}
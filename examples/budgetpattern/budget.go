@@ -0,0 +1,142 @@
+package budgetpattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"rhzx3519/go-concurrency/examples/hedgepattern"
+	"sync"
+	"time"
+)
+
+// RejectedError is returned when a call is not even attempted because its
+// deadline is closer than the call usually takes. It matches
+// context.DeadlineExceeded with errors.Is: the call would most likely have
+// run out of time anyway.
+type RejectedError struct {
+	Op     string
+	Needed time.Duration
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%v: deadline too close, expected to take %v", e.Op, e.Needed)
+}
+
+func (e *RejectedError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// Budget learns how long each operation takes and turns away calls whose
+// context cannot give them that long. The expected duration of an operation
+// is a percentile of its recent calls, failed ones included; until enough are
+// seen it is the initial estimate, zero unless set with WithInitial.
+type Budget struct {
+	clock   clockpattern.Clock
+	p       float64
+	window  int
+	initial map[string]time.Duration
+
+	mu  sync.Mutex
+	ops map[string]*hedgepattern.Percentile
+}
+
+type Option func(*Budget)
+
+// WithWindow sets how many recent calls of each operation are kept, 100 by
+// default.
+func WithWindow(size int) Option {
+	return func(b *Budget) {
+		b.window = size
+	}
+}
+
+// WithInitial sets the expected duration of op before it has a history.
+func WithInitial(op string, d time.Duration) Option {
+	return func(b *Budget) {
+		b.initial[op] = d
+	}
+}
+
+// NewBudget returns a budget that expects an operation to take its p-th
+// percentile latency, p between 0 and 100.
+func NewBudget(clock clockpattern.Clock, p float64, opts ...Option) *Budget {
+	b := &Budget{
+		clock:   clock,
+		p:       p,
+		window:  100,
+		initial: make(map[string]time.Duration),
+		ops:     make(map[string]*hedgepattern.Percentile),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Budget) op(name string) *hedgepattern.Percentile {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.ops[name]
+	if !ok {
+		p = hedgepattern.NewPercentile(b.p, b.window, b.initial[name])
+		b.ops[name] = p
+	}
+	return p
+}
+
+// Estimate returns how long op is expected to take.
+func (b *Budget) Estimate(op string) time.Duration {
+	return b.op(op).Delay()
+}
+
+// Observe records the latency of a call of op.
+func (b *Budget) Observe(op string, latency time.Duration) {
+	b.op(op).Observe(latency)
+}
+
+// Check returns ctx.Err() if ctx is done, and a *RejectedError if ctx has a
+// deadline closer than op is expected to take.
+func (b *Budget) Check(ctx context.Context, op string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return nil
+	}
+	needed := b.Estimate(op)
+	if needed > 0 && deadline.Sub(b.clock.Now()) <= needed {
+		return &RejectedError{Op: op, Needed: needed}
+	}
+	return nil
+}
+
+// Do checks the budget of op, then calls fn and learns from its latency.
+func (b *Budget) Do(ctx context.Context, op string, fn func(ctx context.Context) error) error {
+	_, err := Call(ctx, b, op, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Call is Do for functions that return a value.
+func Call[T any](ctx context.Context, b *Budget, op string, fn func(ctx context.Context) (T, error)) (T, error) {
+	if err := b.Check(ctx, op); err != nil {
+		var zero T
+		return zero, err
+	}
+	start := b.clock.Now()
+	v, err := fn(ctx)
+	// Failures count too, or an operation that always runs out of time would
+	// never get an estimate. For a call cut short by ctx, the time it ran is
+	// a lower bound of the time it needed.
+	b.Observe(op, b.clock.Now().Sub(start))
+	return v, err
+}
+
+// Rejected reports whether err comes from a budget check.
+func Rejected(err error) bool {
+	var rejected *RejectedError
+	return errors.As(err, &rejected)
+}
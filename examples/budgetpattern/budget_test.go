@@ -0,0 +1,99 @@
+package budgetpattern
+
+import (
+	"context"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withTimeout returns a context whose deadline is d from the fake clock's
+// now.
+func withTimeout(clock *clockpattern.Fake, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithDeadline(context.Background(), clock.Now().Add(d))
+}
+
+func TestBudget_Learns(t *testing.T) {
+	clock := clockpattern.NewFake(time.Now())
+	b := NewBudget(clock, 90, WithWindow(20))
+	slowCall := func(ctx context.Context) error {
+		clock.Advance(100 * time.Millisecond)
+		return nil
+	}
+
+	// Nothing known yet, everything goes through.
+	ctx, cancel := withTimeout(clock, time.Hour)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, b.Do(ctx, "lookup", slowCall))
+	}
+	assert.Equal(t, 100*time.Millisecond, b.Estimate("lookup"))
+
+	tight, cancel := withTimeout(clock, 50*time.Millisecond)
+	defer cancel()
+	called := false
+	err := b.Do(tight, "lookup", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.False(t, called)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, Rejected(err))
+	assert.EqualError(t, err, "lookup: deadline too close, expected to take 100ms")
+
+	// Other operations have their own history, and no deadline means no
+	// limit.
+	assert.NoError(t, b.Check(tight, "other"))
+	assert.NoError(t, b.Check(context.Background(), "lookup"))
+}
+
+func TestBudget_Initial(t *testing.T) {
+	clock := clockpattern.NewFake(time.Now())
+	b := NewBudget(clock, 99, WithInitial("locale", time.Minute))
+	ctx, cancel := withTimeout(clock, time.Second)
+	defer cancel()
+	_, err := Call(ctx, b, "locale", func(ctx context.Context) (string, error) {
+		return "en-US", nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel = withTimeout(clock, 2*time.Minute)
+	defer cancel()
+	locale, err := Call(ctx, b, "locale", func(ctx context.Context) (string, error) {
+		return "en-US", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "en-US", locale)
+}
+
+func TestBudget_LearnsFromFailures(t *testing.T) {
+	clock := clockpattern.NewFake(time.Now())
+	b := NewBudget(clock, 50)
+	// An operation that always runs out of time still gets an estimate.
+	for i := 0; i < 20; i++ {
+		err := b.Do(context.Background(), "op", func(ctx context.Context) error {
+			clock.Advance(time.Second)
+			return context.DeadlineExceeded
+		})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, Rejected(err))
+	}
+	assert.Equal(t, time.Second, b.Estimate("op"))
+}
+
+func TestBudget_DoneContext(t *testing.T) {
+	b := NewBudget(clockpattern.NewFake(time.Now()), 50)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Nothing is known about op, but ctx is done already.
+	called := false
+	err := b.Do(ctx, "op", func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.False(t, called)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, Rejected(err))
+}
@@ -0,0 +1,183 @@
+package clockpattern
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock lets time-driven code be tested without sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is a reusable After. As with time.Timer, Stop and Reset report
+// whether the timer was active, and a value sent before they are called may
+// still be waiting in C.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real returns the wall clock.
+func Real() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// Fake is a Clock that only moves when told to.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration // zero for After
+	c      chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{at: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- f.now
+		return w.c
+	}
+	f.waiters = append(f.waiters, w)
+	return w.c
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clockpattern: non-positive interval for NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{at: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	f.waiters = append(f.waiters, w)
+	return &fakeTicker{clock: f, w: w}
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: f, w: &fakeWaiter{c: make(chan time.Time, 1)}}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers that
+// come due on the way. Like real tickers, a ticker whose reader is behind
+// drops ticks.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.now.Add(d)
+	for {
+		sort.Slice(f.waiters, func(i, j int) bool {
+			return f.waiters[i].at.Before(f.waiters[j].at)
+		})
+		if len(f.waiters) == 0 || f.waiters[0].at.After(end) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		select {
+		case w.c <- f.now:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			f.waiters = f.waiters[1:]
+		}
+	}
+	f.now = end
+}
+
+func (f *Fake) remove(w *fakeWaiter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.removeLocked(w)
+}
+
+func (f *Fake) removeLocked(w *fakeWaiter) bool {
+	for i, other := range f.waiters {
+		if other == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTicker struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.c }
+func (t *fakeTicker) Stop()               { t.clock.remove(t.w) }
+
+type fakeTimer struct {
+	clock *Fake
+	w     *fakeWaiter
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.w.c }
+func (t *fakeTimer) Stop() bool          { return t.clock.remove(t.w) }
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	active := f.removeLocked(t.w)
+	t.w.at = f.now.Add(d)
+	if d <= 0 {
+		select {
+		case t.w.c <- f.now:
+		default:
+		}
+		return active
+	}
+	f.waiters = append(f.waiters, t.w)
+	return active
+}
@@ -0,0 +1,65 @@
+package clockpattern
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFake(start)
+	after := clock.After(time.Minute)
+	ticker := clock.NewTicker(20 * time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), clock.Now())
+	assert.Equal(t, start.Add(20*time.Second), <-ticker.C())
+	select {
+	case <-after:
+		t.Fatal("fired too early")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(time.Minute), <-after)
+	// Nobody was reading: the tick at 40s is buffered, the one at 60s dropped.
+	assert.Equal(t, start.Add(40*time.Second), <-ticker.C())
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFake_Timer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFake(start)
+	timer := clock.NewTimer(time.Minute)
+
+	clock.Advance(30 * time.Second)
+	assert.True(t, timer.Reset(time.Minute))
+	clock.Advance(45 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("fired before the reset deadline")
+	default:
+	}
+	clock.Advance(15 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), <-timer.C())
+	assert.False(t, timer.Stop())
+
+	timer.Reset(time.Second)
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Minute)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
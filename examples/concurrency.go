@@ -1,8 +1,27 @@
 package examples
 
-// combine one or more done channels into a single done channel
-// which closes if any of its component channels close.
-func orDone(done, c <-chan interface{}) <-chan interface{} {
+import (
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+// logger is used by the combinators below, it discards everything unless
+// replaced with SetLogger. It may be replaced while they run.
+var logger atomic.Pointer[slog.Logger]
+
+func init() {
+	logger.Store(slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// OrDone forwards the values of c until c is closed or done is, so that a
+// range over c can be cut short. done is any channel that is only ever
+// closed, such as ctx.Done().
+func OrDone[D any](done <-chan D, c <-chan interface{}) <-chan interface{} {
 	valStream := make(chan interface{})
 	go func() {
 		defer close(valStream)
@@ -24,9 +43,9 @@ func orDone(done, c <-chan interface{}) <-chan interface{} {
 	return valStream
 }
 
-// In some circumstances, you may find yourself wanting to consume values
-// from a sequence of channels:
-func bridge(done <-chan interface{}, chanStream <-chan <-chan interface{}) <-chan interface{} {
+// Bridge: in some circumstances, you may find yourself wanting to consume
+// values from a sequence of channels:
+func Bridge(done <-chan interface{}, chanStream <-chan <-chan interface{}) <-chan interface{} {
 	valStream := make(chan interface{})
 	go func() {
 		defer close(valStream)
@@ -41,12 +60,15 @@ func bridge(done <-chan interface{}, chanStream <-chan <-chan interface{}) <-cha
 			case <-done:
 				return
 			}
-			for val := range orDone(done, stream) {
+			n := 0
+			for val := range OrDone(done, stream) {
 				select {
 				case valStream <- val:
+					n++
 				case <-done:
 				}
 			}
+			logger.Load().Debug("stream drained", slog.String("op", "bridge"), slog.Int("values", n))
 		}
 	}()
 	return valStream
@@ -19,7 +19,7 @@ func ExampleBridge() {
 		return chanStream
 	}
 
-	for v := range bridge(nil, genVals()) {
+	for v := range Bridge(nil, genVals()) {
 		fmt.Printf("%v ", v)
 	}
 	// Output: 0 1 2 3 4 5 6 7 8 9
@@ -2,7 +2,15 @@ package contextpattern
 
 import (
 	"context"
+	"embed"
+	"errors"
 	"fmt"
+	"io/fs"
+	"rhzx3519/go-concurrency/examples/budgetpattern"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"rhzx3519/go-concurrency/examples/ctxkey"
+	"rhzx3519/go-concurrency/examples/i18npattern"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,45 +33,97 @@ func printFarewell(ctx context.Context) error {
 	return nil
 }
 
+// Greeting returns the greeting for the caller's locale.
+func Greeting(ctx context.Context) (string, error) {
+	return genGreeting(ctx)
+}
+
+// Farewell returns the farewell for the caller's locale.
+func Farewell(ctx context.Context) (string, error) {
+	return genFarewell(ctx)
+}
+
+//go:embed catalogs/*.json
+var embeddedCatalogs embed.FS
+
+// messages may be replaced while requests are served, see SetCatalog.
+var messages atomic.Pointer[i18npattern.Bundle]
+
+func init() {
+	messages.Store(mustOpenCatalogs())
+	SetLocaleResolver(NewNegotiator(
+		[]string{"en-US", "en-GB", "fr-FR", "de-DE", "es-ES", "it-IT", "ja-JP"},
+		WithFallback("en-US"),
+	), nil)
+}
+
+func mustOpenCatalogs() *i18npattern.Bundle {
+	fsys, err := fs.Sub(embeddedCatalogs, "catalogs")
+	if err != nil {
+		panic(err)
+	}
+	b, err := i18npattern.Open(fsys, "en")
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// SetCatalog replaces the built-in messages, for example with a bundle that
+// is hot reloaded from a directory. Requests already past the lookup finish
+// with the old messages.
+func SetCatalog(b *i18npattern.Bundle) {
+	messages.Store(b)
+}
+
+// localeSetup is swapped as a whole, so that a resolver is never paired with
+// the budget of another one.
+type localeSetup struct {
+	resolver LocaleResolver
+	budget   *budgetpattern.Budget
+}
+
+var locales atomic.Pointer[localeSetup]
+
+func newLocaleBudget() *budgetpattern.Budget {
+	return budgetpattern.NewBudget(clockpattern.Real(), 99)
+}
+
+// SetLocaleResolver replaces the resolver behind genGreeting and genFarewell,
+// along with the budget that learns how long it takes; nil starts a new one.
+// Calls in progress finish with the old resolver.
+func SetLocaleResolver(r LocaleResolver, budget *budgetpattern.Budget) {
+	if budget == nil {
+		budget = newLocaleBudget()
+	}
+	locales.Store(&localeSetup{resolver: r, budget: budget})
+}
+
 func genGreeting(ctx context.Context) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
-	switch locale, err := locale(ctx); {
-	case err != nil:
+	locale, err := locale(ctx)
+	if err != nil {
 		return "", err
-	case locale == "EN/US":
-		return "hello", nil
 	}
-	return "", fmt.Errorf("unsupported locale")
+	return messages.Load().Message(locale, "greeting")
 }
 
 func genFarewell(ctx context.Context) (string, error) {
-	switch locale, err := locale(ctx); {
-	case err != nil:
+	locale, err := locale(ctx)
+	if err != nil {
 		return "", err
-	case locale == "EN/US":
-		return "goodbye", nil
 	}
-	return "", fmt.Errorf("unsupported locale")
+	return messages.Load().Message(locale, "farewell")
 }
 
 func locale(ctx context.Context) (string, error) {
-	// Here we check to see whether our Context has provided a deadline. If it
-	// did, and our system’s clock has advanced past the deadline, we simply
-	// return with a special error defined in the context package,
-	// DeadlineExceeded.
-	if deadline, ok := ctx.Deadline(); ok {
-		if deadline.Sub(time.Now().Add(1*time.Minute)) <= 0 {
-			return "", context.DeadlineExceeded
-		}
-	}
-
-	select {
-	case <-ctx.Done():
-		return "", ctx.Err()
-	case <-time.After(5 * time.Second):
-	}
-	return "EN/US", nil
+	// Here the budget checks whether our Context has provided a deadline. If
+	// it did, and less time is left than the resolver usually takes, we give
+	// up before calling it, with an error that matches the special error
+	// defined in the context package, DeadlineExceeded.
+	setup := locales.Load()
+	return budgetpattern.Call(ctx, setup.budget, "locale", setup.resolver.Resolve)
 }
 
 // //////////////////////////////////////////////////////////////////////
@@ -80,26 +140,35 @@ func locale(ctx context.Context) (string, error) {
 //     Since we don’t export the keys we use to store the data, we must therefore
 //     export functions that retrieve the data for us.
 
-type ctxKey int
+var ErrUnauthenticated = errors.New("unauthenticated")
 
-const (
-	ctxUserID ctxKey = iota
-	ctxAuthToken
+var (
+	userIDKey    = ctxkey.New[string]("user ID")
+	authTokenKey = ctxkey.New[string]("auth token")
 )
 
-func UserID(c context.Context) string {
-	return c.Value(ctxUserID).(string)
+func UserID(c context.Context) (string, bool) {
+	return userIDKey.Get(c)
 }
-func AuthToken(c context.Context) string {
-	return c.Value(ctxAuthToken).(string)
+func AuthToken(c context.Context) (string, bool) {
+	return authTokenKey.Get(c)
 }
 
-func ProcessRequest(userID, authToken string) {
-	ctx := context.WithValue(context.Background(), ctxUserID, userID)
-	ctx = context.WithValue(ctx, ctxAuthToken, authToken)
-	HandleResponse(ctx)
+func ProcessRequest(userID, authToken string) error {
+	ctx := userIDKey.With(context.Background(), userID)
+	ctx = authTokenKey.With(ctx, authToken)
+	return HandleResponse(ctx)
 }
 
-func HandleResponse(ctx context.Context) {
-	fmt.Printf("handling response for %v (auth: %v)", UserID(ctx), AuthToken(ctx))
+func HandleResponse(ctx context.Context) error {
+	userID, ok := UserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	authToken, ok := AuthToken(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+	fmt.Printf("handling response for %v (auth: %v)", userID, authToken)
+	return nil
 }
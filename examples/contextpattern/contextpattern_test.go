@@ -3,7 +3,13 @@ package contextpattern
 import (
 	"context"
 	"fmt"
-	"sync"
+	"rhzx3519/go-concurrency/examples/budgetpattern"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"rhzx3519/go-concurrency/examples/errgrouppattern"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 // Greeting&Farewell Context pattern
@@ -12,33 +18,60 @@ import (
 // smart logic into main. If printGreeting is unsuccessful, we also want to
 // cancel our call to printFarewell. After all, it wouldn’t make sense to say
 // goodbye if we don’t say hello!
-func ExampleContextPattern() {
-	var wg sync.WaitGroup
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+func Example_contextPattern() {
+	// A locale service that takes five seconds to answer, and up to a minute.
+	old := locales.Load()
+	defer SetLocaleResolver(old.resolver, old.budget)
+	SetLocaleResolver(LocaleResolverFunc(func(ctx context.Context) (string, error) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+		return "en-US", nil
+	}), budgetpattern.NewBudget(clockpattern.Real(), 99, budgetpattern.WithInitial("locale", time.Minute)))
+
+	g, ctx := errgrouppattern.WithContext(context.Background())
+	g.Go(func() error {
 		if err := printGreeting(ctx); err != nil {
 			fmt.Printf("cannot print greeting: %v\n", err)
-			cancel()
+			return err
 		}
-	}()
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+		return nil
+	})
+	g.Go(func() error {
 		if err := printFarewell(ctx); err != nil {
 			fmt.Printf("cannot print farewell: %v\n", err)
+			return err
 		}
-	}()
-	wg.Wait()
+		return nil
+	})
+	g.Wait()
 	// Output:
-	// cannot print greeting: context deadline exceeded
+	// cannot print greeting: locale: deadline too close, expected to take 1m0s
 	// cannot print farewell: context canceled
 }
 
-func ExampleContextValue() {
+func Example_contextValue() {
 	ProcessRequest("jane", "abc123")
 	// Output:
 	// handling response for jane (auth: abc123)
 }
+
+func TestHandleResponse_Missing(t *testing.T) {
+	assert.ErrorIs(t, HandleResponse(context.Background()), ErrUnauthenticated)
+
+	ctx := userIDKey.With(context.Background(), "jane")
+	assert.ErrorIs(t, HandleResponse(ctx), ErrUnauthenticated)
+	_, ok := AuthToken(ctx)
+	assert.False(t, ok)
+}
+
+func TestHandleResponse_WrongType(t *testing.T) {
+	// Stored behind ctxkey's back.
+	ctx := context.WithValue(context.Background(), userIDKey, 42)
+	ctx = authTokenKey.With(ctx, "abc123")
+	_, ok := UserID(ctx)
+	assert.False(t, ok)
+	assert.ErrorIs(t, HandleResponse(ctx), ErrUnauthenticated)
+}
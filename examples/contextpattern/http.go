@@ -0,0 +1,190 @@
+package contextpattern
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"rhzx3519/go-concurrency/examples/ctxkey"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Request-scoped values only live as long as the process does. To follow a
+// request to another service they are written to headers on the way out and
+// read back into a new context on the way in.
+
+const (
+	// HeaderUserID is informational: Middleware takes the identity from the
+	// verified token.
+	HeaderUserID    = "X-User-Id"
+	HeaderRequestID = "X-Request-Id"
+	// HeaderTimeout carries the time left rather than the deadline itself,
+	// so that clock skew between the two hosts does not matter.
+	HeaderTimeout = "X-Request-Timeout"
+)
+
+var requestIDKey = ctxkey.New[string]("request ID")
+
+func RequestID(c context.Context) (string, bool) {
+	return requestIDKey.Get(c)
+}
+
+// Metadata is what travels with a request across process boundaries.
+type Metadata struct {
+	UserID    string
+	AuthToken string
+	RequestID string
+	Deadline  time.Time // zero if none
+	// AcceptLanguage is the raw Accept-Language header of the original
+	// request.
+	AcceptLanguage string
+}
+
+// MetadataFrom collects the metadata carried by ctx.
+func MetadataFrom(ctx context.Context) Metadata {
+	var md Metadata
+	md.UserID, _ = UserID(ctx)
+	md.AuthToken, _ = AuthToken(ctx)
+	md.RequestID, _ = RequestID(ctx)
+	md.Deadline, _ = ctx.Deadline()
+	md.AcceptLanguage, _ = acceptLanguageKey.Get(ctx)
+	return md
+}
+
+// WithMetadata returns a context carrying md. Empty fields are left out, and
+// the deadline can only shorten the one of ctx. The returned cancel func must
+// be called.
+func WithMetadata(ctx context.Context, md Metadata) (context.Context, context.CancelFunc) {
+	if md.UserID != "" {
+		ctx = userIDKey.With(ctx, md.UserID)
+	}
+	if md.AuthToken != "" {
+		ctx = authTokenKey.With(ctx, md.AuthToken)
+	}
+	if md.RequestID != "" {
+		ctx = requestIDKey.With(ctx, md.RequestID)
+	}
+	if md.AcceptLanguage != "" {
+		ctx = WithAcceptLanguage(ctx, md.AcceptLanguage)
+	}
+	if md.Deadline.IsZero() {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, md.Deadline)
+}
+
+// Verifier checks a bearer token and returns the ID of the user it was issued
+// to. Identities only get into a request's context through a Verifier.
+type Verifier func(ctx context.Context, token string) (userID string, err error)
+
+// Inject writes the metadata carried by ctx to h, except for the user's
+// identity, see InjectCredentials.
+func Inject(ctx context.Context, h http.Header) {
+	md := MetadataFrom(ctx)
+	if md.RequestID != "" {
+		h.Set(HeaderRequestID, md.RequestID)
+	}
+	if md.AcceptLanguage != "" {
+		h.Set("Accept-Language", md.AcceptLanguage)
+	}
+	if !md.Deadline.IsZero() {
+		h.Set(HeaderTimeout, time.Until(md.Deadline).String())
+	}
+}
+
+// InjectCredentials writes the user ID and auth token carried by ctx to h.
+// They let the receiver act as the user: only send them to trusted hosts.
+func InjectCredentials(ctx context.Context, h http.Header) {
+	md := MetadataFrom(ctx)
+	if md.UserID != "" {
+		h.Set(HeaderUserID, md.UserID)
+	}
+	if md.AuthToken != "" {
+		h.Set("Authorization", "Bearer "+md.AuthToken)
+	}
+}
+
+// Extract reads the metadata written by Inject. The identity is left out:
+// whatever the headers claim, it is only known once the token is verified,
+// see Middleware. A malformed timeout is ignored.
+func Extract(h http.Header) Metadata {
+	md := Metadata{
+		RequestID:      h.Get(HeaderRequestID),
+		AcceptLanguage: h.Get("Accept-Language"),
+	}
+	if timeout, err := time.ParseDuration(h.Get(HeaderTimeout)); err == nil {
+		md.Deadline = time.Now().Add(timeout)
+	}
+	return md
+}
+
+// Transport is an http.RoundTripper that sends the metadata carried by the
+// request's context along with it.
+type Transport struct {
+	// Base is the transport that sends the request, http.DefaultTransport if
+	// nil.
+	Base http.RoundTripper
+	// CredentialHosts are the hosts, as in URL.Host or URL.Hostname, that are
+	// sent the user's credentials. Other hosts get the rest of the metadata.
+	CredentialHosts []string
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	// A RoundTripper must not modify the request it was given.
+	req = req.Clone(req.Context())
+	Inject(req.Context(), req.Header)
+	if t.trusts(req.URL.Host) || t.trusts(req.URL.Hostname()) {
+		InjectCredentials(req.Context(), req.Header)
+	}
+	return base.RoundTrip(req)
+}
+
+func (t *Transport) trusts(host string) bool {
+	return host != "" && slices.ContainsFunc(t.CredentialHosts, func(trusted string) bool {
+		return strings.EqualFold(trusted, host)
+	})
+}
+
+// Middleware decodes the metadata of incoming requests into their context,
+// and cancels it when the caller's deadline passes. A request without an ID
+// is given one. The ID is echoed in the response headers.
+//
+// The identity of the caller comes from verify alone, never from
+// HeaderUserID: a request with a bearer token that verify rejects is
+// answered 401, one without a token goes on unauthenticated. verify must not
+// be nil.
+func Middleware(verify Verifier, next http.Handler) http.Handler {
+	if verify == nil {
+		panic("contextpattern: Middleware needs a Verifier")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		md := Extract(r.Header)
+		if md.RequestID == "" {
+			md.RequestID = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, md.RequestID)
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			userID, err := verify(r.Context(), token)
+			if err != nil || userID == "" {
+				http.Error(w, ErrUnauthenticated.Error(), http.StatusUnauthorized)
+				return
+			}
+			md.UserID, md.AuthToken = userID, token
+		}
+		ctx, cancel := WithMetadata(r.Context(), md)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,151 @@
+package contextpattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// echo replies with the metadata found in the request's context.
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	md := MetadataFrom(r.Context())
+	deadline := "none"
+	if !md.Deadline.IsZero() {
+		deadline = "set"
+	}
+	fmt.Fprintf(w, "user=%v auth=%v request=%v deadline=%v", md.UserID, md.AuthToken, md.RequestID, deadline)
+})
+
+// verify knows a single token, the one of jane.
+func verify(_ context.Context, token string) (string, error) {
+	if token != "abc123" {
+		return "", errors.New("unknown token")
+	}
+	return "jane", nil
+}
+
+// get requests rawURL with a Transport that trusts its host.
+func get(t *testing.T, ctx context.Context, rawURL string) (string, *http.Response) {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	assert.NoError(t, err)
+	return getWith(t, ctx, &Transport{CredentialHosts: []string{u.Host}}, rawURL)
+}
+
+func getWith(t *testing.T, ctx context.Context, transport *Transport, url string) (string, *http.Response) {
+	t.Helper()
+	client := &http.Client{Transport: transport}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	assert.NoError(t, err)
+	resp, err := client.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	return string(body), resp
+}
+
+func TestMiddleware_Propagation(t *testing.T) {
+	server := httptest.NewServer(Middleware(verify, echo))
+	defer server.Close()
+
+	ctx, cancel := WithMetadata(context.Background(), Metadata{
+		UserID:    "jane",
+		AuthToken: "abc123",
+		RequestID: "req-1",
+		Deadline:  time.Now().Add(time.Minute),
+	})
+	defer cancel()
+	body, resp := get(t, ctx, server.URL)
+	assert.Equal(t, "user=jane auth=abc123 request=req-1 deadline=set", body)
+	assert.Equal(t, "req-1", resp.Header.Get(HeaderRequestID))
+
+	// Hosts that are not trusted do not get the credentials.
+	body, _ = getWith(t, ctx, &Transport{CredentialHosts: []string{"auth.example.com"}}, server.URL)
+	assert.Equal(t, "user= auth= request=req-1 deadline=set", body)
+}
+
+func TestMiddleware_Verification(t *testing.T) {
+	server := httptest.NewServer(Middleware(verify, echo))
+	defer server.Close()
+
+	// A claimed user ID without a token is ignored.
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	assert.NoError(t, err)
+	req.Header.Set(HeaderUserID, "admin")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "user= auth= ")
+
+	// The user ID is the one the token was issued to, not the claimed one.
+	req.Header.Set("Authorization", "Bearer abc123")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "user=jane auth=abc123 ")
+
+	req.Header.Set("Authorization", "Bearer forged")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	assert.Panics(t, func() { Middleware(nil, echo) })
+}
+
+func TestMiddleware_NoMetadata(t *testing.T) {
+	server := httptest.NewServer(Middleware(verify, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := HandleResponse(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+	})))
+	defer server.Close()
+
+	body, resp := get(t, context.Background(), server.URL)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "unauthenticated\n", body)
+	// An ID is made up for requests that come without one.
+	assert.Len(t, resp.Header.Get(HeaderRequestID), 16)
+}
+
+func TestMiddleware_Deadline(t *testing.T) {
+	canceled := make(chan error, 1)
+	server := httptest.NewServer(Middleware(verify, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		canceled <- r.Context().Err()
+	})))
+	defer server.Close()
+
+	// A bare client: the server enforces the time left on its own.
+	req := httptest.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set(HeaderTimeout, "20ms")
+	req.RequestURI = ""
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("handler context not canceled")
+	}
+}
+
+func TestExtract_Malformed(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Basic amFuZTpwdw==")
+	h.Set(HeaderUserID, "jane")
+	h.Set(HeaderTimeout, "soon")
+	assert.Equal(t, Metadata{}, Extract(h))
+}
@@ -0,0 +1,238 @@
+package contextpattern
+
+import (
+	"context"
+	"errors"
+	"rhzx3519/go-concurrency/examples/ctxkey"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocaleResolver decides which locale to answer a request in.
+type LocaleResolver interface {
+	Resolve(ctx context.Context) (string, error)
+}
+
+// LocaleResolverFunc adapts a function to a LocaleResolver.
+type LocaleResolverFunc func(ctx context.Context) (string, error)
+
+func (f LocaleResolverFunc) Resolve(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Preferences looks up the locale a user chose. It returns "" if the user
+// has no preference.
+type Preferences interface {
+	Locale(ctx context.Context, userID string) (string, error)
+}
+
+var ErrNoLocale = errors.New("no supported locale")
+
+var acceptLanguageKey = ctxkey.New[string]("accept language")
+
+// WithAcceptLanguage returns a context carrying the value of an
+// Accept-Language header.
+func WithAcceptLanguage(ctx context.Context, acceptLanguage string) context.Context {
+	return acceptLanguageKey.With(ctx, acceptLanguage)
+}
+
+// maxNegotiated and maxUserLocales bound the caches of negotiated
+// Accept-Language headers and of user preferences, both keyed by what
+// clients send.
+const (
+	maxNegotiated  = 1024
+	maxUserLocales = 1024
+)
+
+// Negotiator resolves, in order: the user's preference, then the request's
+// Accept-Language, then the fallback chain. Locales are tags such as "en-US",
+// compared case-insensitively; a language alone ("fr") matches the first
+// supported locale of that language.
+type Negotiator struct {
+	supported []string
+	fallback  []string
+	prefs     Preferences
+	prefsTTL  time.Duration
+
+	mu         sync.Mutex
+	userLocale map[string]cachedLocale // by user ID
+	negotiated map[string]string       // by Accept-Language
+}
+
+type cachedLocale struct {
+	locale  string
+	expires time.Time
+}
+
+type NegotiatorOption func(*Negotiator)
+
+// WithPreferences consults prefs first, keeping each answer for ttl.
+func WithPreferences(prefs Preferences, ttl time.Duration) NegotiatorOption {
+	return func(n *Negotiator) {
+		n.prefs = prefs
+		n.prefsTTL = ttl
+	}
+}
+
+// WithFallback sets the locales tried, in order, when nothing else matches.
+// By default the first supported locale is used.
+func WithFallback(chain ...string) NegotiatorOption {
+	return func(n *Negotiator) {
+		n.fallback = chain
+	}
+}
+
+func NewNegotiator(supported []string, opts ...NegotiatorOption) *Negotiator {
+	n := &Negotiator{
+		supported:  supported,
+		userLocale: make(map[string]cachedLocale),
+		negotiated: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Resolve gives up with ctx.Err() once ctx is done. A failing preference
+// lookup is otherwise skipped.
+func (n *Negotiator) Resolve(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if userID, ok := UserID(ctx); ok && n.prefs != nil {
+		preferred, err := n.preference(ctx, userID)
+		if err != nil && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if locale, ok := n.match(preferred); ok {
+			return locale, nil
+		}
+	}
+	if acceptLanguage, ok := acceptLanguageKey.Get(ctx); ok {
+		if locale, ok := n.negotiate(acceptLanguage); ok {
+			return locale, nil
+		}
+	}
+	for _, tag := range n.fallback {
+		if locale, ok := n.match(tag); ok {
+			return locale, nil
+		}
+	}
+	if len(n.supported) == 0 {
+		return "", ErrNoLocale
+	}
+	return n.supported[0], nil
+}
+
+func (n *Negotiator) preference(ctx context.Context, userID string) (string, error) {
+	n.mu.Lock()
+	cached, ok := n.userLocale[userID]
+	n.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.locale, nil
+	}
+
+	locale, err := n.prefs.Locale(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	if len(n.userLocale) >= maxUserLocales {
+		// Make room by dropping the expired entries, or everything if none
+		// has expired.
+		for id, cached := range n.userLocale {
+			if !now.Before(cached.expires) {
+				delete(n.userLocale, id)
+			}
+		}
+		if len(n.userLocale) >= maxUserLocales {
+			clear(n.userLocale)
+		}
+	}
+	n.userLocale[userID] = cachedLocale{locale: locale, expires: now.Add(n.prefsTTL)}
+	return locale, nil
+}
+
+func (n *Negotiator) negotiate(acceptLanguage string) (string, bool) {
+	n.mu.Lock()
+	locale, ok := n.negotiated[acceptLanguage]
+	n.mu.Unlock()
+	if ok {
+		return locale, locale != ""
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if locale, ok = n.match(tag); ok {
+			break
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.negotiated) >= maxNegotiated {
+		clear(n.negotiated)
+	}
+	n.negotiated[acceptLanguage] = locale
+	return locale, ok
+}
+
+// match finds the supported locale for tag, exactly or by language.
+func (n *Negotiator) match(tag string) (string, bool) {
+	if tag == "" {
+		return "", false
+	}
+	if tag == "*" && len(n.supported) > 0 {
+		return n.supported[0], true
+	}
+	for _, locale := range n.supported {
+		if strings.EqualFold(locale, tag) {
+			return locale, true
+		}
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	for _, locale := range n.supported {
+		if l, _, _ := strings.Cut(locale, "-"); strings.EqualFold(l, lang) {
+			return locale, true
+		}
+	}
+	return "", false
+}
+
+// parseAcceptLanguage returns the tags of an Accept-Language header by
+// decreasing quality, dropping the ones with q=0. Malformed entries are
+// skipped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
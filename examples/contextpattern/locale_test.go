@@ -0,0 +1,189 @@
+package contextpattern
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"rhzx3519/go-concurrency/examples/budgetpattern"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"rhzx3519/go-concurrency/examples/i18npattern"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"fr-CH", "fr", "*", "en"},
+		parseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0, *;q=0.85"))
+	assert.Equal(t, []string{"es"}, parseAcceptLanguage(" , es ;q=1, it;q=high, pt;q=2"))
+	assert.Empty(t, parseAcceptLanguage(""))
+}
+
+func TestNegotiator_AcceptLanguage(t *testing.T) {
+	n := NewNegotiator([]string{"en-US", "en-GB", "fr-FR"}, WithFallback("en-GB"))
+	for _, tt := range []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"fr-FR", "fr-FR"},
+		{"EN-gb", "en-GB"},
+		{"fr-CA, en;q=0.5", "fr-FR"}, // by language
+		{"de, en-GB;q=0.1", "en-GB"},
+		{"de", "en-GB"}, // fallback chain
+		{"*", "en-US"},
+		{"", "en-GB"},
+	} {
+		ctx := WithAcceptLanguage(context.Background(), tt.acceptLanguage)
+		for i := 0; i < 2; i++ { // the second time from the cache
+			locale, err := n.Resolve(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, locale, tt.acceptLanguage)
+		}
+	}
+
+	_, err := NewNegotiator(nil).Resolve(context.Background())
+	assert.ErrorIs(t, err, ErrNoLocale)
+}
+
+type fakePreferences struct {
+	lookups atomic.Int32
+	locale  func(ctx context.Context, userID string) (string, error)
+}
+
+func (p *fakePreferences) Locale(ctx context.Context, userID string) (string, error) {
+	p.lookups.Add(1)
+	return p.locale(ctx, userID)
+}
+
+func TestNegotiator_Preferences(t *testing.T) {
+	prefs := &fakePreferences{locale: func(ctx context.Context, userID string) (string, error) {
+		switch userID {
+		case "jane":
+			return "fr", nil
+		case "john":
+			return "", errors.New("preferences unavailable")
+		}
+		return "", nil
+	}}
+	n := NewNegotiator([]string{"en-US", "fr-FR"}, WithPreferences(prefs, time.Minute))
+
+	ctx := WithAcceptLanguage(userIDKey.With(context.Background(), "jane"), "en-US")
+	for i := 0; i < 3; i++ {
+		locale, err := n.Resolve(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "fr-FR", locale)
+	}
+	assert.Equal(t, int32(1), prefs.lookups.Load())
+
+	// Without a usable preference, Accept-Language decides.
+	for _, userID := range []string{"john", "anonymous"} {
+		ctx = WithAcceptLanguage(userIDKey.With(context.Background(), userID), "fr")
+		locale, err := n.Resolve(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "fr-FR", locale)
+	}
+}
+
+func TestNegotiator_PreferencesBounded(t *testing.T) {
+	prefs := &fakePreferences{locale: func(ctx context.Context, userID string) (string, error) {
+		return "fr", nil
+	}}
+	n := NewNegotiator([]string{"en-US", "fr-FR"}, WithPreferences(prefs, time.Minute))
+	for i := 0; i < 3*maxUserLocales; i++ {
+		_, err := n.Resolve(userIDKey.With(context.Background(), strconv.Itoa(i)))
+		assert.NoError(t, err)
+	}
+	assert.LessOrEqual(t, len(n.userLocale), maxUserLocales)
+}
+
+func TestNegotiator_Deadline(t *testing.T) {
+	prefs := &fakePreferences{locale: func(ctx context.Context, userID string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+	n := NewNegotiator([]string{"en-US"}, WithPreferences(prefs, time.Minute))
+
+	ctx, cancel := context.WithTimeout(userIDKey.With(context.Background(), "jane"), 10*time.Millisecond)
+	defer cancel()
+	_, err := n.Resolve(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = n.Resolve(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGreeting_Locales(t *testing.T) {
+	server := httptest.NewServer(Middleware(verify, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		greeting, err := genGreeting(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		farewell, err := genFarewell(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(greeting + ", " + farewell))
+	})))
+	defer server.Close()
+
+	for acceptLanguage, want := range map[string]string{
+		"fr-CA,fr;q=0.9":  "bonjour, au revoir",
+		"de-AT":           "hallo, auf Wiedersehen",
+		"es;q=0.8, it":    "ciao, arrivederci",
+		"pt-BR, es;q=0.5": "hola, adiós",
+		"ja":              "こんにちは, さようなら",
+		"ko":              "hello, goodbye",
+		"en-GB, en;q=0.9": "hello, goodbye",
+	} {
+		ctx := WithAcceptLanguage(context.Background(), acceptLanguage)
+		body, resp := get(t, ctx, server.URL)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, want, body, acceptLanguage)
+	}
+}
+
+func TestSetCatalog(t *testing.T) {
+	b, err := i18npattern.Open(fstest.MapFS{
+		"en.json": {Data: []byte(`{"messages": {"greeting": "howdy"}}`)},
+	})
+	assert.NoError(t, err)
+	old := messages.Load()
+	defer SetCatalog(old)
+	SetCatalog(b)
+
+	greeting, err := genGreeting(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "howdy", greeting)
+	_, err = genFarewell(context.Background())
+	assert.ErrorIs(t, err, i18npattern.ErrNoMessage)
+}
+
+func TestLocale_Budget(t *testing.T) {
+	var calls atomic.Int32
+	old := locales.Load()
+	defer SetLocaleResolver(old.resolver, old.budget)
+	SetLocaleResolver(LocaleResolverFunc(func(ctx context.Context) (string, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return "en-US", nil
+	}), budgetpattern.NewBudget(clockpattern.Real(), 90))
+
+	// Learn how long the resolver takes.
+	for i := 0; i < 10; i++ {
+		_, err := genFarewell(context.Background())
+		assert.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, err := genFarewell(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, budgetpattern.Rejected(err))
+	assert.Equal(t, int32(10), calls.Load())
+}
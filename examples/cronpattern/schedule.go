@@ -0,0 +1,227 @@
+package cronpattern
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	second, minute, hour, dom, month, dow uint64
+	loc                                   *time.Location // nil: the scheduler's location
+}
+
+type bounds struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondBounds = bounds{name: "second", min: 0, max: 59}
+	minuteBounds = bounds{name: "minute", min: 0, max: 59}
+	hourBounds   = bounds{name: "hour", min: 0, max: 23}
+	domBounds    = bounds{name: "day of month", min: 1, max: 31}
+	monthBounds  = bounds{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is accepted for Sunday and folded into 0.
+	dowBounds = bounds{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// star marks a field written as "*" or "?", it matters for the day of month
+// and day of week rule.
+const star = 1 << 63
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse parses a standard 5-field expression (minute hour dom month dow), a
+// 6-field one with a leading seconds field, or a descriptor such as @daily.
+// A "CRON_TZ=Area/City " prefix sets the time zone of the schedule.
+func Parse(spec string) (*Schedule, error) {
+	s := &Schedule{}
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tz, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(tz, "=")
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %v", spec, err)
+		}
+		s.loc = loc
+		spec = strings.TrimSpace(rest)
+	}
+	if d, ok := descriptors[spec]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("parse %q: expected 5 or 6 fields, found %d", spec, len(fields))
+	}
+
+	var err error
+	for i, f := range []struct {
+		dst *uint64
+		b   bounds
+	}{
+		{&s.second, secondBounds},
+		{&s.minute, minuteBounds},
+		{&s.hour, hourBounds},
+		{&s.dom, domBounds},
+		{&s.month, monthBounds},
+		{&s.dow, dowBounds},
+	} {
+		if *f.dst, err = parseField(fields[i], f.b); err != nil {
+			return nil, fmt.Errorf("parse %q: %v", spec, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	return s, nil
+}
+
+// parseField parses a comma separated list of "*", "a", "a-b", each with an
+// optional "/step".
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		r, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("%s: bad step %q", b.name, stepStr)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case r == "*" || r == "?":
+			lo, hi = b.min, b.max
+			if !hasStep {
+				bits |= star
+			}
+		default:
+			loStr, hiStr, isRange := strings.Cut(r, "-")
+			var err error
+			if lo, err = b.value(loStr); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = b.value(hiStr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// "a/step" means from a to the end.
+				hi = b.max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: bad range %q", b.name, r)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (b bounds) value(s string) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < b.min || v > b.max {
+		return 0, fmt.Errorf("%s: %q out of range [%d, %d]", b.name, s, b.min, b.max)
+	}
+	return v, nil
+}
+
+// Next returns the first activation strictly after t, in the schedule's time
+// zone if it has one, else in t's. It returns the zero time if there is none
+// within five years (e.g. "0 0 30 2 *").
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	if s.loc != nil {
+		loc = s.loc
+	}
+	origLoc := t.Location()
+	t = t.In(loc).Truncate(time.Second).Add(time.Second)
+	yearLimit := t.Year() + 5
+
+	// Walk from the largest unit down, resetting the smaller ones as soon as
+	// a larger one moves.
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for !has(s.month, int(t.Month())) {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for !has(s.hour, t.Hour()) {
+		// Add absolute time rather than going through time.Date, which is
+		// ambiguous in a DST gap.
+		t = t.Add(-time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.minute, t.Minute()) {
+		t = t.Add(-time.Duration(t.Second()) * time.Second).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	for !has(s.second, t.Second()) {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+	return t.In(origLoc)
+}
+
+// dayMatches follows the cron rule: if both day fields are restricted, a day
+// matching either one is enough.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := has(s.dom, t.Day())
+	dowMatch := has(s.dow, int(t.Weekday()))
+	if s.dom&star != 0 || s.dow&star != 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<v) != 0
+}
@@ -0,0 +1,64 @@
+package cronpattern
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse_Errors(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * foo *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"CRON_TZ=Nowhere/Special * * * * *",
+	} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	from := time.Date(2024, 2, 28, 23, 59, 30, 0, time.UTC) // a Wednesday
+	for _, tt := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"* * * * * *", time.Date(2024, 2, 28, 23, 59, 31, 0, time.UTC)},
+		{"*/15 * * * * *", time.Date(2024, 2, 28, 23, 59, 45, 0, time.UTC)},
+		{"30 9 * * mon-fri", time.Date(2024, 2, 29, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches.
+		{"0 0 13 * fri", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+		{"@monthly", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		s, err := Parse(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.want, s.Next(from), tt.spec)
+	}
+}
+
+func TestSchedule_TimeZone(t *testing.T) {
+	s, err := Parse("CRON_TZ=Asia/Tokyo 0 9 * * *")
+	assert.NoError(t, err)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) // 09:00 in Tokyo
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), s.Next(from))
+
+	// Across a DST change the wall clock time is kept.
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	s, _ = Parse("0 9 * * *")
+	from = time.Date(2024, 3, 9, 12, 0, 0, 0, ny)
+	assert.Equal(t, time.Date(2024, 3, 10, 9, 0, 0, 0, ny), s.Next(from))
+}
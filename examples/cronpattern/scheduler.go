@@ -0,0 +1,230 @@
+package cronpattern
+
+import (
+	"context"
+	"math/rand/v2"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync"
+	"time"
+)
+
+// Overlap tells what to do when a job is due while its previous run has not
+// finished.
+type Overlap int
+
+const (
+	// Skip drops the new run.
+	Skip Overlap = iota
+	// Queue runs it once the previous run returns.
+	Queue
+	// CancelPrevious cancels the previous run and starts the new one once it
+	// has returned.
+	CancelPrevious
+)
+
+type Job func(ctx context.Context)
+
+type EntryID int
+
+type EntryOption func(*entry)
+
+func WithOverlap(policy Overlap) EntryOption {
+	return func(e *entry) {
+		e.overlap = policy
+	}
+}
+
+// WithJitter delays every run by a random duration up to d, so that jobs
+// scheduled at the same time do not all hit the database at once.
+func WithJitter(d time.Duration) EntryOption {
+	return func(e *entry) {
+		e.jitter = d
+	}
+}
+
+// Scheduler runs jobs on cron schedules.
+type Scheduler struct {
+	clock clockpattern.Clock
+	loc   *time.Location
+
+	mu      sync.Mutex
+	entries map[EntryID]*entry
+	nextID  EntryID
+	changed chan struct{} // wakes up the loop when entries change
+	ctx     context.Context
+	jobs    sync.WaitGroup
+	done    chan struct{}
+}
+
+type entry struct {
+	id       EntryID
+	schedule *Schedule
+	job      Job
+	overlap  Overlap
+	jitter   time.Duration
+	next     time.Time
+
+	running bool
+	pending int
+	cancel  context.CancelFunc
+}
+
+// NewScheduler returns a scheduler evaluating the schedules in loc, unless a
+// schedule has its own CRON_TZ. A nil loc means time.Local.
+func NewScheduler(clock clockpattern.Clock, loc *time.Location) *Scheduler {
+	if loc == nil {
+		loc = time.Local
+	}
+	return &Scheduler{
+		clock:   clock,
+		loc:     loc,
+		entries: make(map[EntryID]*entry),
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Add schedules job according to spec, see Parse.
+func (s *Scheduler) Add(spec string, job Job, opts ...EntryOption) (EntryID, error) {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	e := &entry{id: s.nextID, schedule: schedule, job: job}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.next = schedule.Next(s.clock.Now().In(s.loc))
+	s.entries[e.id] = e
+	s.notify()
+	return e.id, nil
+}
+
+// Remove unschedules a job. A run in progress is not canceled.
+func (s *Scheduler) Remove(id EntryID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[id]; ok {
+		// Drop the queued runs, the running one is left to finish.
+		e.pending = 0
+		delete(s.entries, id)
+	}
+	s.notify()
+}
+
+// Run starts the scheduler. When ctx is done no more runs are started and the
+// running ones are canceled; Done is closed once they have all returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+	go func() {
+		defer close(s.done)
+		defer s.jobs.Wait()
+		// One timer for the whole loop, rearmed for the earliest entry.
+		timer := s.clock.NewTimer(0)
+		defer timer.Stop()
+		for {
+			if !timer.Stop() {
+				select {
+				case <-timer.C():
+				default:
+				}
+			}
+			var wait <-chan time.Time
+			if next, ok := s.earliest(); ok {
+				timer.Reset(next.Sub(s.clock.Now()))
+				wait = timer.C()
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.changed:
+			case <-wait:
+				s.fireDue()
+			}
+		}
+	}()
+}
+
+func (s *Scheduler) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Scheduler) earliest() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, e := range s.entries {
+		if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+			next = e.next
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func (s *Scheduler) fireDue() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	for _, e := range s.entries {
+		if e.next.IsZero() || e.next.After(now) {
+			continue
+		}
+		e.next = e.schedule.Next(now.In(s.loc))
+		s.fire(e)
+	}
+}
+
+// fire applies the overlap policy of e. It is called with s.mu held.
+func (s *Scheduler) fire(e *entry) {
+	if e.running {
+		switch e.overlap {
+		case Skip:
+		case Queue:
+			e.pending++
+		case CancelPrevious:
+			e.pending = 1
+			e.cancel()
+		}
+		return
+	}
+	s.start(e)
+}
+
+func (s *Scheduler) start(e *entry) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	e.running, e.cancel = true, cancel
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		defer cancel()
+		if e.jitter > 0 {
+			select {
+			case <-ctx.Done():
+			case <-s.clock.After(rand.N(e.jitter)):
+			}
+		}
+		if ctx.Err() == nil {
+			e.job(ctx)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		e.running = false
+		if e.pending > 0 && s.entries[e.id] == e && s.ctx.Err() == nil {
+			e.pending--
+			s.start(e)
+		}
+	}()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
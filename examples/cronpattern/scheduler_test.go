@@ -0,0 +1,161 @@
+package cronpattern
+
+import (
+	"context"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestScheduler() (*Scheduler, *clockpattern.Fake) {
+	clock := clockpattern.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	return NewScheduler(clock, time.UTC), clock
+}
+
+// tick advances the clock by one second and waits for the run it triggers.
+func tick(t *testing.T, clock *clockpattern.Fake, started <-chan struct{}) {
+	t.Helper()
+	clock.Advance(time.Second)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("job did not start")
+	}
+}
+
+func TestScheduler_Skip(t *testing.T) {
+	s, clock := newTestScheduler()
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	var runs atomic.Int32
+	s.Add("* * * * * *", func(ctx context.Context) {
+		runs.Add(1)
+		started <- struct{}{}
+		<-release
+	}, WithOverlap(Skip))
+	ctx, cancel := context.WithCancel(context.TODO())
+	s.Run(ctx)
+
+	tick(t, clock, started)
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+	}
+	// Let the loop see every run due before releasing the first one.
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.entries[1].next.After(clock.Now())
+	}, time.Second, time.Millisecond)
+	close(release)
+	cancel()
+	<-s.Done()
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+func TestScheduler_Queue(t *testing.T) {
+	s, clock := newTestScheduler()
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	s.Add("* * * * * *", func(ctx context.Context) {
+		started <- struct{}{}
+		<-release
+	}, WithOverlap(Queue))
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	s.Run(ctx)
+
+	tick(t, clock, started)
+	// Wait for the loop to take each run into account before the next one.
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Second)
+		assert.Eventually(t, func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.entries[1].pending == i+1
+		}, time.Second, time.Millisecond)
+	}
+	close(release)
+	// The two queued runs follow.
+	<-started
+	<-started
+}
+
+func TestScheduler_CancelPrevious(t *testing.T) {
+	s, clock := newTestScheduler()
+	started := make(chan struct{}, 10)
+	var canceled atomic.Int32
+	s.Add("* * * * * *", func(ctx context.Context) {
+		started <- struct{}{}
+		<-ctx.Done()
+		canceled.Add(1)
+	}, WithOverlap(CancelPrevious))
+	ctx, cancel := context.WithCancel(context.TODO())
+	s.Run(ctx)
+
+	tick(t, clock, started)
+	tick(t, clock, started)
+	assert.Equal(t, int32(1), canceled.Load())
+
+	// Shutting down cancels the last run too.
+	cancel()
+	<-s.Done()
+	assert.Equal(t, int32(2), canceled.Load())
+}
+
+func TestScheduler_Remove(t *testing.T) {
+	s, clock := newTestScheduler()
+	var runs atomic.Int32
+	id, err := s.Add("* * * * * *", func(context.Context) { runs.Add(1) })
+	assert.NoError(t, err)
+	_, err = s.Add("bad", nil)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	s.Run(ctx)
+	s.Remove(id)
+	clock.Advance(time.Minute)
+	cancel()
+	<-s.Done()
+	assert.Equal(t, int32(0), runs.Load())
+}
+
+func TestScheduler_RemoveDropsQueuedRuns(t *testing.T) {
+	s, clock := newTestScheduler()
+	started, release := make(chan struct{}, 10), make(chan struct{})
+	id, _ := s.Add("* * * * * *", func(ctx context.Context) {
+		started <- struct{}{}
+		<-release
+	}, WithOverlap(Queue))
+	ctx, cancel := context.WithCancel(context.TODO())
+	s.Run(ctx)
+
+	tick(t, clock, started)
+	clock.Advance(time.Second)
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.entries[id].pending == 1
+	}, time.Second, time.Millisecond)
+	s.mu.Lock()
+	e := s.entries[id]
+	s.mu.Unlock()
+	s.Remove(id)
+	close(release)
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return !e.running
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, started)
+	cancel()
+	<-s.Done()
+}
+
+func TestScheduler_NilLocation(t *testing.T) {
+	clock := clockpattern.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := NewScheduler(clock, nil)
+	assert.Equal(t, time.Local, s.loc)
+	_, err := s.Add("0 0 * * * *", func(context.Context) {})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,56 @@
+package ctxkey
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key is a typed context key. Each Key is its own key: two keys never
+// collide, even with the same name and type, so the unexported key type
+// convention is not needed.
+type Key[T any] struct {
+	name       string
+	def        T
+	hasDefault bool
+}
+
+// New returns a key without a default value. name is only used in messages.
+func New[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// NewDefault returns a key whose Get and MustGet fall back to def when ctx
+// carries no value for it.
+func NewDefault[T any](name string, def T) *Key[T] {
+	return &Key[T]{name: name, def: def, hasDefault: true}
+}
+
+func (k *Key[T]) With(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k, v)
+}
+
+// Get returns the value of k in ctx and whether it was set. A nil interface,
+// or a value of the wrong type stored under k with context.WithValue, counts
+// as not set; a nil pointer is set. When not set, Get returns the default, or
+// the zero value if there is none.
+func (k *Key[T]) Get(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	if !ok {
+		return k.def, false
+	}
+	return v, true
+}
+
+// MustGet is like Get but panics if the value is not set and k has no
+// default.
+func (k *Key[T]) MustGet(ctx context.Context) T {
+	v, ok := k.Get(ctx)
+	if !ok && !k.hasDefault {
+		panic(fmt.Sprintf("ctxkey: %v not set: %T in context", k.name, ctx.Value(k)))
+	}
+	return v
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
@@ -0,0 +1,70 @@
+package ctxkey
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleKey() {
+	userID := New[string]("user ID")
+	retries := NewDefault("retries", 3)
+
+	ctx := userID.With(context.Background(), "jane")
+	fmt.Println(userID.MustGet(ctx))
+	fmt.Println(retries.MustGet(ctx))
+	// Output:
+	// jane
+	// 3
+}
+
+func TestKey_Missing(t *testing.T) {
+	userID := New[string]("user ID")
+	v, ok := userID.Get(context.Background())
+	assert.False(t, ok)
+	assert.Equal(t, "", v)
+	assert.PanicsWithValue(t, "ctxkey: user ID not set: <nil> in context", func() {
+		userID.MustGet(context.Background())
+	})
+}
+
+func TestKey_WrongType(t *testing.T) {
+	userID := New[string]("user ID")
+	ctx := context.WithValue(context.Background(), userID, 42)
+	_, ok := userID.Get(ctx)
+	assert.False(t, ok)
+	assert.PanicsWithValue(t, "ctxkey: user ID not set: int in context", func() {
+		userID.MustGet(ctx)
+	})
+
+	retries := NewDefault("retries", 3)
+	ctx = context.WithValue(context.Background(), retries, "many")
+	v, ok := retries.Get(ctx)
+	assert.False(t, ok)
+	assert.Equal(t, 3, v)
+}
+
+func TestKey_Distinct(t *testing.T) {
+	a, b := New[string]("id"), New[string]("id")
+	ctx := a.With(context.Background(), "a")
+	_, ok := b.Get(ctx)
+	assert.False(t, ok)
+
+	// The innermost value wins.
+	ctx = a.With(ctx, "a2")
+	assert.Equal(t, "a2", a.MustGet(ctx))
+
+	// An interface is set by a non-nil value; a nil interface counts as not
+	// set, a nil pointer does not.
+	err := NewDefault[error]("err", nil)
+	v, ok := err.Get(err.With(context.Background(), fmt.Errorf("boom")))
+	assert.True(t, ok)
+	assert.EqualError(t, v, "boom")
+	_, ok = err.Get(err.With(context.Background(), nil))
+	assert.False(t, ok)
+	p := New[*int]("p")
+	_, ok = p.Get(p.With(context.Background(), nil))
+	assert.True(t, ok)
+}
@@ -0,0 +1,148 @@
+package errgrouppattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is returned for a goroutine that panicked.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap lets errors.Is/As see through a panic with an error value.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Group runs goroutines working on subtasks of a common task. By default the
+// first error cancels the group's context and is the one returned by Wait;
+// with CollectErrors every error is kept and joined instead.
+type Group struct {
+	cancel  context.CancelCauseFunc
+	wg      sync.WaitGroup
+	sem     chan struct{}
+	collect bool
+
+	mu   sync.Mutex
+	errs []error
+}
+
+// WithContext returns a new Group and a derived context which is canceled
+// the first time a function returns an error (unless CollectErrors is set)
+// or when Wait returns.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit limits the number of active goroutines to n. A negative value
+// means no limit. It must not be called while goroutines are active.
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("errgroup: modify limit while %v goroutines are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// CollectErrors makes Wait return every error joined with errors.Join, and
+// stops errors from canceling the group's context.
+func (g *Group) CollectErrors() {
+	g.collect = true
+}
+
+// Go calls f in a new goroutine, blocking until the limit allows it. A panic
+// in f is recovered and reported as a *PanicError.
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(f)
+}
+
+// TryGo calls f in a new goroutine only if the limit allows it right now.
+func (g *Group) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(f)
+	return true
+}
+
+// Wait blocks until every function has returned, then returns the first
+// error, or all of them joined when CollectErrors is set.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	err := g.result()
+	if g.cancel != nil {
+		g.cancel(err)
+	}
+	return err
+}
+
+func (g *Group) start(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := call(f); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+func (g *Group) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.collect && len(g.errs) > 0 {
+		return
+	}
+	g.errs = append(g.errs, err)
+	if !g.collect && g.cancel != nil {
+		g.cancel(err)
+	}
+}
+
+func (g *Group) result() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.collect {
+		return errors.Join(g.errs...)
+	}
+	if len(g.errs) > 0 {
+		return g.errs[0]
+	}
+	return nil
+}
+
+func call(f func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return f()
+}
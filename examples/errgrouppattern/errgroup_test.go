@@ -0,0 +1,80 @@
+package errgrouppattern
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_FirstErrorCancels(t *testing.T) {
+	g, ctx := WithContext(context.TODO())
+	boom := errors.New("boom")
+	g.Go(func() error {
+		return boom
+	})
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.ErrorIs(t, g.Wait(), boom)
+	assert.ErrorIs(t, context.Cause(ctx), boom)
+}
+
+func TestGroup_CollectErrors(t *testing.T) {
+	g, ctx := WithContext(context.TODO())
+	g.CollectErrors()
+	first, second := errors.New("first"), errors.New("second")
+	g.Go(func() error { return first })
+	g.Go(func() error { return second })
+	g.Go(func() error { return nil })
+
+	err := g.Wait()
+	assert.ErrorIs(t, err, first)
+	assert.ErrorIs(t, err, second)
+	assert.Error(t, ctx.Err())
+}
+
+func TestGroup_SetLimit(t *testing.T) {
+	var g Group
+	g.SetLimit(2)
+	var inFlight, peak atomic.Int32
+	for i := 0; i < 20; i++ {
+		g.Go(func() error {
+			cur := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if cur <= p || peak.CompareAndSwap(p, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+			return nil
+		})
+	}
+	assert.NoError(t, g.Wait())
+	assert.Equal(t, int32(2), peak.Load())
+
+	release := make(chan struct{})
+	g.SetLimit(1)
+	assert.True(t, g.TryGo(func() error { <-release; return nil }))
+	assert.False(t, g.TryGo(func() error { return nil }))
+	close(release)
+	assert.NoError(t, g.Wait())
+}
+
+func TestGroup_Panic(t *testing.T) {
+	var g Group
+	boom := errors.New("boom")
+	g.Go(func() error { panic(boom) })
+
+	err := g.Wait()
+	var panicErr *PanicError
+	assert.ErrorAs(t, err, &panicErr)
+	assert.ErrorIs(t, err, boom)
+	assert.Contains(t, string(panicErr.Stack), "errgroup_test.go")
+}
@@ -0,0 +1,171 @@
+package futurepattern
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrCanceled = errors.New("future canceled")
+
+// Future is a one-shot result. It is completed exactly once, either by
+// Complete, by Cancel or by the function started with Go; later completions
+// are ignored.
+type Future[T any] struct {
+	done   chan struct{}
+	once   sync.Once
+	val    T
+	err    error
+	cancel context.CancelFunc // cancels the work behind the future, if any
+}
+
+func New[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Go runs fn in a new goroutine and returns a future of its result. Canceling
+// the future cancels the context passed to fn.
+func Go[T any](ctx context.Context, fn func(ctx context.Context) (T, error)) *Future[T] {
+	f := New[T]()
+	ctx, f.cancel = context.WithCancel(ctx)
+	go func() {
+		defer f.cancel()
+		f.Complete(fn(ctx))
+	}()
+	return f
+}
+
+// Complete sets the result of the future. It reports whether this call was
+// the one completing it.
+func (f *Future[T]) Complete(val T, err error) bool {
+	completed := false
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+		completed = true
+	})
+	return completed
+}
+
+// Cancel completes the future with ErrCanceled and cancels the work behind
+// it.
+func (f *Future[T]) Cancel() bool {
+	var zero T
+	completed := f.Complete(zero, ErrCanceled)
+	if f.cancel != nil {
+		f.cancel()
+	}
+	return completed
+}
+
+// Done is closed once the future is completed.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Await waits for the result. Giving up on ctx does not cancel the future.
+func (f *Future[T]) Await(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Then returns a future of fn applied to the result of f. An error of f is
+// passed through without calling fn.
+func Then[T, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	next := New[U]()
+	go func() {
+		select {
+		case <-f.done:
+		case <-next.done:
+			// Canceled before f completed.
+			return
+		}
+		if f.err != nil {
+			var zero U
+			next.Complete(zero, f.err)
+			return
+		}
+		next.Complete(fn(f.val))
+	}()
+	return next
+}
+
+// All returns a future of every result, in order. It fails with the first
+// error and cancels the others.
+func All[T any](fs ...*Future[T]) *Future[[]T] {
+	all := New[[]T]()
+	go func() {
+		vals := make([]T, len(fs))
+		for i, f := range fs {
+			select {
+			case <-f.done:
+			case <-all.done:
+				cancelAll(fs)
+				return
+			}
+			if f.err != nil {
+				all.Complete(nil, f.err)
+				cancelAll(fs)
+				return
+			}
+			vals[i] = f.val
+		}
+		all.Complete(vals, nil)
+	}()
+	return all
+}
+
+// Any returns a future of the first successful result and cancels the
+// others. If every future fails, the errors are joined.
+func Any[T any](fs ...*Future[T]) *Future[T] {
+	first := New[T]()
+	if len(fs) == 0 {
+		var zero T
+		first.Complete(zero, errors.New("any of no futures"))
+		return first
+	}
+	go func() {
+		defer cancelAll(fs)
+		errStream := make(chan error, len(fs))
+		for _, f := range fs {
+			go func(f *Future[T]) {
+				select {
+				case <-f.done:
+				case <-first.done:
+					return
+				}
+				if f.err == nil {
+					first.Complete(f.val, nil)
+				}
+				errStream <- f.err
+			}(f)
+		}
+
+		var errs []error
+		for range fs {
+			select {
+			case err := <-errStream:
+				if err == nil {
+					return
+				}
+				errs = append(errs, err)
+			case <-first.done:
+				return
+			}
+		}
+		var zero T
+		first.Complete(zero, errors.Join(errs...))
+	}()
+	return first
+}
+
+func cancelAll[T any](fs []*Future[T]) {
+	for _, f := range fs {
+		f.Cancel()
+	}
+}
@@ -0,0 +1,116 @@
+package futurepattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleThen() {
+	count := Go(context.TODO(), func(context.Context) (int, error) {
+		return 41, nil
+	})
+	label := Then(count, func(n int) (string, error) {
+		return "reading: " + strconv.Itoa(n+1), nil
+	})
+	fmt.Println(label.Await(context.TODO()))
+	// Output:
+	// reading: 42 <nil>
+}
+
+func TestFuture_Complete(t *testing.T) {
+	f := New[int]()
+	assert.True(t, f.Complete(1, nil))
+	assert.False(t, f.Complete(2, nil))
+	assert.False(t, f.Cancel())
+
+	v, err := f.Await(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestFuture_Cancel(t *testing.T) {
+	canceled := make(chan struct{})
+	f := Go(context.TODO(), func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(canceled)
+		return 0, ctx.Err()
+	})
+	assert.True(t, f.Cancel())
+	<-canceled
+
+	_, err := f.Await(context.TODO())
+	assert.ErrorIs(t, err, ErrCanceled)
+}
+
+func TestFuture_AwaitTimeout(t *testing.T) {
+	f := New[int]()
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err := f.Await(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Giving up does not complete the future.
+	assert.True(t, f.Complete(1, nil))
+}
+
+func TestThen_Error(t *testing.T) {
+	boom := errors.New("boom")
+	f := New[int]()
+	f.Complete(0, boom)
+	next := Then(f, func(int) (int, error) {
+		t.Error("must not be called")
+		return 0, nil
+	})
+	_, err := next.Await(context.TODO())
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestAll(t *testing.T) {
+	fs := []*Future[int]{New[int](), New[int](), New[int]()}
+	all := All(fs...)
+	fs[2].Complete(3, nil)
+	fs[0].Complete(1, nil)
+	fs[1].Complete(2, nil)
+	vals, err := all.Await(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, vals)
+
+	boom := errors.New("boom")
+	fs = []*Future[int]{New[int](), New[int]()}
+	all = All(fs...)
+	fs[0].Complete(0, boom)
+	_, err = all.Await(context.TODO())
+	assert.ErrorIs(t, err, boom)
+	// The pending one is canceled.
+	_, err = fs[1].Await(context.TODO())
+	assert.ErrorIs(t, err, ErrCanceled)
+}
+
+func TestAny(t *testing.T) {
+	boom := errors.New("boom")
+	fs := []*Future[int]{New[int](), New[int](), New[int]()}
+	first := Any(fs...)
+	fs[0].Complete(0, boom)
+	fs[2].Complete(3, nil)
+	v, err := first.Await(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, v)
+	_, err = fs[1].Await(context.TODO())
+	assert.ErrorIs(t, err, ErrCanceled)
+
+	fs = []*Future[int]{New[int](), New[int]()}
+	first = Any(fs...)
+	fs[0].Complete(0, boom)
+	fs[1].Complete(0, boom)
+	_, err = first.Await(context.TODO())
+	assert.ErrorIs(t, err, boom)
+
+	_, err = Any[int]().Await(context.TODO())
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package hedgepattern
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Delay tells Hedge how long to wait for an attempt before starting the next
+// one.
+type Delay interface {
+	Delay() time.Duration
+}
+
+// Observer is implemented by delays that learn from the latency of
+// successful attempts, see Percentile.
+type Observer interface {
+	Observe(latency time.Duration)
+}
+
+// Fixed is a constant hedging delay.
+type Fixed time.Duration
+
+func (d Fixed) Delay() time.Duration {
+	return time.Duration(d)
+}
+
+type result[T any] struct {
+	val T
+	err error
+}
+
+// Hedge calls fn and, if it has not returned after delay, starts up to
+// maxHedges duplicate attempts. The first success is returned and the context
+// passed to the other attempts is canceled. A failed attempt starts the next
+// hedge right away; if every attempt fails the errors are joined.
+func Hedge[T any](ctx context.Context, delay Delay, maxHedges int, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered, so that the losers never block once we have returned.
+	resultStream := make(chan result[T], maxHedges+1)
+	observer, _ := delay.(Observer)
+	attempt := func() {
+		start := time.Now()
+		val, err := fn(ctx)
+		if err == nil && observer != nil {
+			observer.Observe(time.Since(start))
+		}
+		resultStream <- result[T]{val: val, err: err}
+	}
+
+	var errs []error
+	launched, inFlight := 0, 0
+	launch := func() {
+		launched++
+		inFlight++
+		go attempt()
+	}
+
+	launch()
+	timer := time.NewTimer(delay.Delay())
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case <-timer.C:
+			if launched <= maxHedges {
+				launch()
+				timer.Reset(delay.Delay())
+			}
+		case r := <-resultStream:
+			inFlight--
+			if r.err == nil {
+				return r.val, nil
+			}
+			errs = append(errs, r.err)
+			if launched <= maxHedges {
+				launch()
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(delay.Delay())
+			} else if inFlight == 0 {
+				var zero T
+				return zero, errors.Join(errs...)
+			}
+		}
+	}
+}
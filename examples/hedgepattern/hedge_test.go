@@ -0,0 +1,83 @@
+package hedgepattern
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedge_SlowFirstAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	var canceled atomic.Int32
+	v, err := Hedge(context.TODO(), Fixed(10*time.Millisecond), 2, func(ctx context.Context) (int, error) {
+		n := attempts.Add(1)
+		if n == 1 {
+			// The first attempt hangs until the hedge wins.
+			<-ctx.Done()
+			canceled.Add(1)
+			return 0, ctx.Err()
+		}
+		return int(n), nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, v)
+	assert.Eventually(t, func() bool { return canceled.Load() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestHedge_FastFirstAttempt(t *testing.T) {
+	var attempts atomic.Int32
+	v, err := Hedge(context.TODO(), Fixed(time.Second), 2, func(ctx context.Context) (string, error) {
+		attempts.Add(1)
+		return "reading", nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "reading", v)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestHedge_AllFail(t *testing.T) {
+	var attempts atomic.Int32
+	boom := errors.New("boom")
+	_, err := Hedge(context.TODO(), Fixed(time.Second), 2, func(ctx context.Context) (int, error) {
+		attempts.Add(1)
+		return 0, boom
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestHedge_ContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.TODO(), 20*time.Millisecond)
+	defer cancel()
+	_, err := Hedge(ctx, Fixed(5*time.Millisecond), 1, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPercentile(t *testing.T) {
+	d := NewPercentile(90, 100, time.Second)
+	assert.Equal(t, time.Second, d.Delay())
+
+	for i := 1; i <= 100; i++ {
+		d.Observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 90*time.Millisecond, d.Delay())
+
+	// Old samples are overwritten.
+	for i := 0; i < 100; i++ {
+		d.Observe(time.Millisecond)
+	}
+	assert.Equal(t, time.Millisecond, d.Delay())
+}
+
+func TestPercentile_ZeroSize(t *testing.T) {
+	d := NewPercentile(90, 0, time.Second)
+	d.Observe(time.Millisecond)
+	assert.Equal(t, time.Second, d.Delay())
+}
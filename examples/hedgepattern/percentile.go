@@ -0,0 +1,58 @@
+package hedgepattern
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Percentile is a Delay that hedges once an attempt is slower than the given
+// percentile of the last size observed latencies. Until enough samples are
+// collected it uses fallback. A size below 1 keeps a single sample.
+type Percentile struct {
+	p        float64
+	fallback time.Duration
+	mu       sync.Mutex
+	samples  []time.Duration
+	next     int
+	full     bool
+}
+
+func NewPercentile(p float64, size int, fallback time.Duration) *Percentile {
+	size = max(size, 1)
+	return &Percentile{
+		p:        p,
+		fallback: fallback,
+		samples:  make([]time.Duration, size),
+	}
+}
+
+func (d *Percentile) Observe(latency time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.samples[d.next] = latency
+	d.next = (d.next + 1) % len(d.samples)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+func (d *Percentile) Delay() time.Duration {
+	d.mu.Lock()
+	n := d.next
+	if d.full {
+		n = len(d.samples)
+	}
+	// Too few samples to say anything about the tail.
+	if n < 10 {
+		d.mu.Unlock()
+		return d.fallback
+	}
+	sorted := slices.Clone(d.samples[:n])
+	d.mu.Unlock()
+
+	slices.Sort(sorted)
+	i := int(d.p/100*float64(n)+0.5) - 1
+	i = max(0, min(i, n-1))
+	return sorted[i]
+}
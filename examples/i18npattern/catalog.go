@@ -0,0 +1,227 @@
+package i18npattern
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var ErrNoMessage = errors.New("no such message")
+
+// Catalog is the content of one JSON catalog file:
+//
+//	{
+//	  "locale": "fr-CA",
+//	  "fallback": "fr",
+//	  "messages": {
+//	    "greeting": "allô",
+//	    "visitors": {"one": "{n} visiteur", "other": "{n} visiteurs"}
+//	  }
+//	}
+//
+// If locale is omitted, the file name without its extension is used.
+type Catalog struct {
+	Locale   string             `json:"locale"`
+	Fallback string             `json:"fallback,omitempty"`
+	Messages map[string]Message `json:"messages"`
+}
+
+// Message is either a plain text or a set of plural forms keyed by plural
+// category.
+type Message struct {
+	Text  string
+	Forms map[string]string
+}
+
+func (m *Message) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("{")) {
+		return json.Unmarshal(b, &m.Forms)
+	}
+	return json.Unmarshal(b, &m.Text)
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	if m.Forms != nil {
+		return json.Marshal(m.Forms)
+	}
+	return json.Marshal(m.Text)
+}
+
+// Bundle serves messages from the catalogs found at the root of a file
+// system. Reload swaps all of them at once: a lookup sees either the old
+// catalogs or the new ones, never a mix.
+type Bundle struct {
+	fsys     fs.FS
+	fallback []string
+	catalogs atomic.Pointer[catalogs]
+}
+
+type catalogs struct {
+	byLocale map[string]*Catalog // by lower-case locale
+	digest   [sha256.Size]byte
+}
+
+// Open loads the *.json catalogs of fsys. fallback is the chain of locales
+// tried after a locale's own fallbacks.
+func Open(fsys fs.FS, fallback ...string) (*Bundle, error) {
+	b := &Bundle{fsys: fsys, fallback: fallback}
+	if _, err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reload reads the catalogs again and reports whether they changed. On error
+// the current catalogs are kept.
+func (b *Bundle) Reload() (bool, error) {
+	names, err := fs.Glob(b.fsys, "*.json")
+	if err != nil {
+		return false, err
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	files := make([][]byte, len(names))
+	for i, name := range names {
+		if files[i], err = fs.ReadFile(b.fsys, name); err != nil {
+			return false, err
+		}
+		h.Write([]byte(name))
+		h.Write(files[i])
+	}
+	var digest [sha256.Size]byte
+	h.Sum(digest[:0])
+	if current := b.catalogs.Load(); current != nil && current.digest == digest {
+		return false, nil
+	}
+
+	next := &catalogs{byLocale: make(map[string]*Catalog), digest: digest}
+	for i, name := range names {
+		var c Catalog
+		if err := json.Unmarshal(files[i], &c); err != nil {
+			return false, fmt.Errorf("catalog %v: %w", name, err)
+		}
+		if c.Locale == "" {
+			c.Locale = strings.TrimSuffix(path.Base(name), ".json")
+		}
+		key := strings.ToLower(c.Locale)
+		if _, ok := next.byLocale[key]; ok {
+			return false, fmt.Errorf("catalog %v: duplicate locale %v", name, c.Locale)
+		}
+		next.byLocale[key] = &c
+	}
+	b.catalogs.Store(next)
+	return true, nil
+}
+
+// Watch reloads the catalogs every interval until ctx is done. Errors are
+// passed to onError, if not nil, and the last good catalogs stay in use.
+func (b *Bundle) Watch(ctx context.Context, clock clockpattern.Clock, interval time.Duration, onError func(error)) {
+	go func() {
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if _, err := b.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Locales returns the locales that have a catalog, sorted.
+func (b *Bundle) Locales() []string {
+	cs := b.catalogs.Load()
+	locales := make([]string, 0, len(cs.byLocale))
+	for _, c := range cs.byLocale {
+		locales = append(locales, c.Locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Message returns the text of message id in locale. For a plural message, it
+// is the Other form.
+func (b *Bundle) Message(locale, id string) (string, error) {
+	c, m, ok := b.lookup(locale, id)
+	if !ok {
+		return "", fmt.Errorf("%w: %v in %v", ErrNoMessage, id, locale)
+	}
+	return m.form(c.Locale, -1), nil
+}
+
+// Plural returns the form of message id for n in locale, with {n} replaced
+// by n. The form is chosen by the rules of the catalog the message comes
+// from, which may be a fallback.
+func (b *Bundle) Plural(locale, id string, n int) (string, error) {
+	c, m, ok := b.lookup(locale, id)
+	if !ok {
+		return "", fmt.Errorf("%w: %v in %v", ErrNoMessage, id, locale)
+	}
+	return strings.ReplaceAll(m.form(c.Locale, n), "{n}", strconv.Itoa(n)), nil
+}
+
+func (m Message) form(locale string, n int) string {
+	if m.Forms == nil {
+		return m.Text
+	}
+	if n >= 0 {
+		if text, ok := m.Forms[PluralCategory(locale, n)]; ok {
+			return text
+		}
+	}
+	return m.Forms[Other]
+}
+
+// lookup walks the fallback chain of locale: the locale itself, the fallbacks
+// named by its catalogs, its language alone, then the bundle's fallbacks.
+func (b *Bundle) lookup(locale, id string) (*Catalog, Message, bool) {
+	cs := b.catalogs.Load()
+	visited := make(map[string]bool)
+	var try func(locale string) (*Catalog, Message, bool)
+	try = func(locale string) (*Catalog, Message, bool) {
+		key := strings.ToLower(locale)
+		if key == "" || visited[key] {
+			return nil, Message{}, false
+		}
+		visited[key] = true
+		c, ok := cs.byLocale[key]
+		if ok {
+			if m, ok := c.Messages[id]; ok {
+				return c, m, true
+			}
+			if c, m, ok := try(c.Fallback); ok {
+				return c, m, true
+			}
+		}
+		if lang, _, ok := strings.Cut(key, "-"); ok {
+			return try(lang)
+		}
+		return nil, Message{}, false
+	}
+
+	if c, m, ok := try(locale); ok {
+		return c, m, true
+	}
+	for _, fallback := range b.fallback {
+		if c, m, ok := try(fallback); ok {
+			return c, m, true
+		}
+	}
+	return nil, Message{}, false
+}
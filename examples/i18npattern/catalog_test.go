@@ -0,0 +1,137 @@
+package i18npattern
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCatalogs = fstest.MapFS{
+	"en.json": {Data: []byte(`{"messages": {
+		"greeting": "hello",
+		"farewell": "goodbye",
+		"visitors": {"one": "{n} visitor", "other": "{n} visitors"}
+	}}`)},
+	"en-GB.json": {Data: []byte(`{"messages": {"farewell": "cheerio"}}`)},
+	"fr.json": {Data: []byte(`{"messages": {
+		"greeting": "bonjour",
+		"visitors": {"one": "{n} visiteur", "other": "{n} visiteurs"}
+	}}`)},
+	"fr-CA.json": {Data: []byte(`{"locale": "fr-CA", "fallback": "fr", "messages": {"greeting": "allô"}}`)},
+	"ru.json": {Data: []byte(`{"messages": {
+		"visitors": {"one": "{n} посетитель", "few": "{n} посетителя", "many": "{n} посетителей"}
+	}}`)},
+	"README.md": {Data: []byte("not a catalog")},
+}
+
+func TestBundle_Message(t *testing.T) {
+	b, err := Open(testCatalogs, "en")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"en", "en-GB", "fr", "fr-CA", "ru"}, b.Locales())
+
+	for _, tt := range []struct {
+		locale, id, want string
+	}{
+		{"en", "greeting", "hello"},
+		{"EN-gb", "farewell", "cheerio"},
+		{"en-GB", "greeting", "hello"}, // by language
+		{"en-US", "greeting", "hello"},
+		{"fr-CA", "greeting", "allô"},
+		{"fr-CA", "farewell", "goodbye"}, // bundle fallback
+		{"fr-BE", "greeting", "bonjour"},
+		{"ja", "greeting", "hello"},
+		{"fr", "visitors", "{n} visiteurs"},
+	} {
+		got, err := b.Message(tt.locale, tt.id)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%v %v", tt.locale, tt.id)
+	}
+
+	_, err = b.Message("fr", "nope")
+	assert.ErrorIs(t, err, ErrNoMessage)
+	b, err = Open(testCatalogs)
+	assert.NoError(t, err)
+	_, err = b.Message("ja", "greeting")
+	assert.ErrorIs(t, err, ErrNoMessage)
+}
+
+func TestBundle_Plural(t *testing.T) {
+	b, err := Open(testCatalogs, "en")
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en", 1, "1 visitor"},
+		{"en", 0, "0 visitors"},
+		{"fr", 0, "0 visiteur"},
+		{"fr-CA", 2, "2 visiteurs"},
+		{"ru", 21, "21 посетитель"},
+		{"ru", 3, "3 посетителя"},
+		{"ru", 12, "12 посетителей"},
+		// Falls back to English, and to its rules.
+		{"de", 0, "0 visitors"},
+	} {
+		got, err := b.Plural(tt.locale, "visitors", tt.n)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	_, err := Open(fstest.MapFS{"en.json": {Data: []byte(`{"messages": [`)}})
+	assert.ErrorContains(t, err, "catalog en.json")
+	_, err = Open(fstest.MapFS{
+		"a.json": {Data: []byte(`{"locale": "en"}`)},
+		"b.json": {Data: []byte(`{"locale": "EN"}`)},
+	})
+	assert.ErrorContains(t, err, "duplicate locale")
+}
+
+func TestBundle_Watch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(data string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "en.json"), []byte(data), 0o644))
+	}
+	write(`{"messages": {"greeting": "hello"}}`)
+	b, err := Open(os.DirFS(dir))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	clock := clockpattern.NewFake(time.Now())
+	var errs atomic.Int32
+	b.Watch(ctx, clock, time.Second, func(error) { errs.Add(1) })
+
+	greeting := func() string {
+		clock.Advance(time.Second)
+		s, _ := b.Message("en", "greeting")
+		return s
+	}
+	write(`{"messages": {"greeting": "hi"}}`)
+	assert.Eventually(t, func() bool { return greeting() == "hi" }, time.Second, time.Millisecond)
+
+	// A broken catalog is reported, the last good one stays.
+	write(`{"messages": `)
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		return errs.Load() > 0
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, "hi", greeting())
+
+	changed, err := b.Reload()
+	assert.Error(t, err)
+	assert.False(t, changed)
+	write(`{"messages": {"greeting": "hi"}}`)
+	changed, err = b.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
@@ -0,0 +1,72 @@
+package i18npattern
+
+import "strings"
+
+// Plural categories, as named by CLDR.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// PluralRule picks the plural category of n.
+type PluralRule func(n int) string
+
+// pluralRules covers the integer rules of a few languages; a language that is
+// not listed only has Other.
+var pluralRules = map[string]PluralRule{
+	"en": oneOther,
+	"de": oneOther,
+	"es": oneOther,
+	"it": oneOther,
+	"nl": oneOther,
+	"fr": func(n int) string {
+		if n == 0 || n == 1 {
+			return One
+		}
+		return Other
+	},
+	"ru": slavic,
+	"uk": slavic,
+	"pl": func(n int) string {
+		switch {
+		case n == 1:
+			return One
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return Few
+		}
+		return Many
+	},
+}
+
+func oneOther(n int) string {
+	if n == 1 {
+		return One
+	}
+	return Other
+}
+
+func slavic(n int) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return One
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return Few
+	}
+	return Many
+}
+
+// PluralCategory returns the plural category of n in locale.
+func PluralCategory(locale string, n int) string {
+	if n < 0 {
+		n = -n
+	}
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	if rule, ok := pluralRules[lang]; ok {
+		return rule(n)
+	}
+	return Other
+}
@@ -0,0 +1,35 @@
+package i18npattern
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluralCategory(t *testing.T) {
+	for _, tt := range []struct {
+		locale string
+		n      int
+		want   string
+	}{
+		{"en-US", 0, Other},
+		{"en-US", 1, One},
+		{"en", 2, Other},
+		{"fr-FR", 0, One},
+		{"fr", 1, One},
+		{"fr", 2, Other},
+		{"ru", 1, One},
+		{"ru", 11, Many},
+		{"ru", 21, One},
+		{"ru", 3, Few},
+		{"ru", 13, Many},
+		{"ru", 5, Many},
+		{"pl", 1, One},
+		{"pl", 21, Many},
+		{"pl", 22, Few},
+		{"ja-JP", 1, Other},
+		{"EN", -1, One},
+	} {
+		assert.Equal(t, tt.want, PluralCategory(tt.locale, tt.n), "%v %v", tt.locale, tt.n)
+	}
+}
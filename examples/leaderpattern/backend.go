@@ -0,0 +1,108 @@
+package leaderpattern
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Backend stores leases. TryAcquire takes the named lease for holder until
+// expiresAt if it is free, expired at now, or already held by holder (a
+// renewal). It reports whether holder holds the lease afterwards.
+type Backend interface {
+	TryAcquire(ctx context.Context, name, holder string, now, expiresAt time.Time) (bool, error)
+	Release(ctx context.Context, name, holder string) error
+}
+
+// MemoryBackend keeps the leases in memory, for tests.
+type MemoryBackend struct {
+	mu     sync.Mutex
+	leases map[string]lease
+}
+
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{leases: make(map[string]lease)}
+}
+
+func (b *MemoryBackend) TryAcquire(ctx context.Context, name, holder string, now, expiresAt time.Time) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.leases[name]
+	if ok && l.holder != holder && now.Before(l.expiresAt) {
+		return false, nil
+	}
+	b.leases[name] = lease{holder: holder, expiresAt: expiresAt}
+	return true, nil
+}
+
+func (b *MemoryBackend) Release(ctx context.Context, name, holder string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if l, ok := b.leases[name]; ok && l.holder == holder {
+		delete(b.leases, name)
+	}
+	return nil
+}
+
+// MysqlBackend keeps the leases in a table:
+//
+//	CREATE TABLE `leases` (
+//	  `name` varchar(64) NOT NULL,
+//	  `holder` varchar(255) NOT NULL,
+//	  `expires_at` datetime(6) NOT NULL,
+//	  PRIMARY KEY (`name`)
+//	) ENGINE=InnoDB
+//
+// Times come from the instances, so their clocks must roughly agree; keep the
+// ttl well above the expected skew.
+type MysqlBackend struct {
+	db *sql.DB
+}
+
+func NewMysqlBackend(db *sql.DB) *MysqlBackend {
+	return &MysqlBackend{db: db}
+}
+
+func (b *MysqlBackend) TryAcquire(ctx context.Context, name, holder string, now, expiresAt time.Time) (bool, error) {
+	// Renew our lease or take an expired one.
+	result, err := b.db.ExecContext(ctx,
+		"UPDATE leases SET holder = ?, expires_at = ? WHERE name = ? AND (holder = ? OR expires_at < ?)",
+		holder, expiresAt, name, holder, now)
+	if err != nil {
+		return false, fmt.Errorf("tryAcquire %v: %v", name, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("tryAcquire %v: %v", name, err)
+	} else if n == 1 {
+		return true, nil
+	}
+
+	// No row updated: either the lease is held by someone else, or there is
+	// no lease yet.
+	result, err = b.db.ExecContext(ctx,
+		"INSERT IGNORE INTO leases (name, holder, expires_at) VALUES (?, ?, ?)",
+		name, holder, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("tryAcquire %v: %v", name, err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("tryAcquire %v: %v", name, err)
+	}
+	return n == 1, nil
+}
+
+func (b *MysqlBackend) Release(ctx context.Context, name, holder string) error {
+	if _, err := b.db.ExecContext(ctx,
+		"DELETE FROM leases WHERE name = ? AND holder = ?", name, holder); err != nil {
+		return fmt.Errorf("release %v: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package leaderpattern
+
+import (
+	"context"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync/atomic"
+	"time"
+)
+
+// Elector competes for a named lease and keeps renewing it while it holds
+// it. It steps down as soon as a renewal fails: past that point another
+// instance may take the lease once it expires.
+type Elector struct {
+	backend Backend
+	clock   clockpattern.Clock
+	name    string
+	id      string
+	ttl     time.Duration
+
+	leader           atomic.Bool
+	term             uint64 // only touched by the Run goroutine
+	leadershipStream chan Term
+}
+
+// Term is a leadership change. Every time the instance becomes the leader a
+// new term starts, numbered from 1; Leader is false when it steps down from
+// term Number.
+type Term struct {
+	Number uint64
+	Leader bool
+}
+
+// NewElector returns an elector for the lease name, identified by id. The
+// lease is renewed every ttl/3.
+func NewElector(backend Backend, clock clockpattern.Clock, name, id string, ttl time.Duration) *Elector {
+	return &Elector{
+		backend:          backend,
+		clock:            clock,
+		name:             name,
+		id:               id,
+		ttl:              ttl,
+		leadershipStream: make(chan Term, 1),
+	}
+}
+
+// Run campaigns until ctx is done, then releases the lease if held and
+// closes the Leadership channel.
+func (e *Elector) Run(ctx context.Context) {
+	go func() {
+		defer close(e.leadershipStream)
+		ticker := e.clock.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			e.campaign(ctx)
+			select {
+			case <-ctx.Done():
+				if e.leader.Load() {
+					// Best effort, the lease expires anyway.
+					releaseCtx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+					e.backend.Release(releaseCtx, e.name, e.id)
+					cancel()
+					e.setLeader(false)
+				}
+				return
+			case <-ticker.C():
+			}
+		}
+	}()
+}
+
+// Leadership delivers a Term when this instance becomes the leader and when
+// it steps down. A slow reader only misses intermediate changes, never the
+// latest one: leadership lost and regained in between reads as a new term.
+func (e *Elector) Leadership() <-chan Term {
+	return e.leadershipStream
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+func (e *Elector) campaign(ctx context.Context) {
+	// A renewal that does not come back before the next one is due counts as
+	// failed.
+	ctx, cancel := context.WithTimeout(ctx, e.ttl/3)
+	defer cancel()
+	now := e.clock.Now()
+	acquired, err := e.backend.TryAcquire(ctx, e.name, e.id, now, now.Add(e.ttl))
+	e.setLeader(err == nil && acquired)
+}
+
+func (e *Elector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		e.term++
+	}
+	// Replace any change the reader has not seen yet.
+	select {
+	case <-e.leadershipStream:
+	default:
+	}
+	e.leadershipStream <- Term{Number: e.term, Leader: leader}
+}
+
+// WhileLeader runs job for every term, canceling it when leadership is lost.
+// A new term cancels the run of the previous one before starting the next,
+// even if the loss in between was missed. It returns once leadership is
+// closed and the last run has returned.
+func WhileLeader(leadership <-chan Term, job func(ctx context.Context)) {
+	var stop func()
+	var current uint64
+	defer func() {
+		if stop != nil {
+			stop()
+		}
+	}()
+	for term := range leadership {
+		if stop != nil && (!term.Leader || term.Number != current) {
+			stop()
+			stop = nil
+		}
+		if term.Leader && stop == nil {
+			current = term.Number
+			stop = start(job)
+		}
+	}
+}
+
+// start runs job in a new goroutine. The returned func cancels it and waits
+// for it to return.
+func start(job func(ctx context.Context)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		job(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,116 @@
+package leaderpattern
+
+import (
+	"context"
+	"errors"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ttl = 30 * time.Second
+
+func nextChange(t *testing.T, leadership <-chan Term) Term {
+	t.Helper()
+	select {
+	case term := <-leadership:
+		return term
+	case <-time.After(time.Second):
+		t.Fatal("no leadership change")
+		return Term{}
+	}
+}
+
+func TestElector_Failover(t *testing.T) {
+	backend := NewMemoryBackend()
+	clock := clockpattern.NewFake(time.Now())
+
+	ctxA, cancelA := context.WithCancel(context.TODO())
+	a := NewElector(backend, clock, "flusher", "a", ttl)
+	a.Run(ctxA)
+	assert.Equal(t, Term{Number: 1, Leader: true}, nextChange(t, a.Leadership()))
+
+	ctxB, cancelB := context.WithCancel(context.TODO())
+	defer cancelB()
+	b := NewElector(backend, clock, "flusher", "b", ttl)
+	b.Run(ctxB)
+	clock.Advance(ttl / 3)
+	assert.False(t, b.IsLeader())
+
+	// a steps down cleanly and releases the lease, b takes over on its next
+	// round.
+	cancelA()
+	assert.Equal(t, Term{Number: 1, Leader: false}, nextChange(t, a.Leadership()))
+	_, ok := <-a.Leadership()
+	assert.False(t, ok)
+	clock.Advance(ttl / 3)
+	assert.True(t, nextChange(t, b.Leadership()).Leader)
+}
+
+type flakyBackend struct {
+	Backend
+	fail atomic.Bool
+}
+
+func (b *flakyBackend) TryAcquire(ctx context.Context, name, holder string, now, expiresAt time.Time) (bool, error) {
+	if b.fail.Load() {
+		return false, errors.New("connection refused")
+	}
+	return b.Backend.TryAcquire(ctx, name, holder, now, expiresAt)
+}
+
+func TestElector_StepsDownWhenRenewalFails(t *testing.T) {
+	backend := &flakyBackend{Backend: NewMemoryBackend()}
+	clock := clockpattern.NewFake(time.Now())
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	elector := NewElector(backend, clock, "flusher", "a", ttl)
+	elector.Run(ctx)
+	assert.Equal(t, Term{Number: 1, Leader: true}, nextChange(t, elector.Leadership()))
+
+	backend.fail.Store(true)
+	clock.Advance(ttl / 3)
+	assert.Equal(t, Term{Number: 1, Leader: false}, nextChange(t, elector.Leadership()))
+
+	backend.fail.Store(false)
+	clock.Advance(ttl / 3)
+	assert.Equal(t, Term{Number: 2, Leader: true}, nextChange(t, elector.Leadership()))
+
+	// A reader that missed the loss still sees a new term.
+	backend.fail.Store(true)
+	clock.Advance(ttl / 3)
+	assert.Eventually(t, func() bool { return !elector.IsLeader() }, time.Second, time.Millisecond)
+	backend.fail.Store(false)
+	clock.Advance(ttl / 3)
+	assert.Eventually(t, elector.IsLeader, time.Second, time.Millisecond)
+	assert.Equal(t, Term{Number: 3, Leader: true}, nextChange(t, elector.Leadership()))
+}
+
+func TestWhileLeader(t *testing.T) {
+	leadership := make(chan Term)
+	var runs, canceled atomic.Int32
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WhileLeader(leadership, func(ctx context.Context) {
+			runs.Add(1)
+			<-ctx.Done()
+			canceled.Add(1)
+		})
+	}()
+
+	leadership <- Term{1, true}
+	leadership <- Term{1, true} // still the same term, no second run
+	leadership <- Term{1, false}
+	leadership <- Term{2, true}
+	// The loss of term 2 was missed: the run of term 2 is still canceled
+	// before the one of term 3 starts.
+	leadership <- Term{3, true}
+	close(leadership)
+	<-done
+	assert.Equal(t, int32(3), runs.Load())
+	assert.Equal(t, int32(3), canceled.Load())
+}
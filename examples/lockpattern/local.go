@@ -0,0 +1,67 @@
+package lockpattern
+
+import (
+	"context"
+	"sync"
+)
+
+// localLocks makes the callers of one Locker take turns on each name. The
+// backends only tell Lockers apart, not the goroutines sharing one, so
+// without it a second caller would find the lock already held by "itself".
+type localLocks struct {
+	mu    sync.Mutex
+	names map[string]*localLock
+}
+
+type localLock struct {
+	sem  chan struct{}
+	refs int // holder and waiters, the entry goes when it drops to zero
+}
+
+// lock waits for name to be free in this process, or for ctx to be done.
+func (l *localLocks) lock(ctx context.Context, name string) error {
+	l.mu.Lock()
+	if l.names == nil {
+		l.names = make(map[string]*localLock)
+	}
+	ll, ok := l.names[name]
+	if !ok {
+		ll = &localLock{sem: make(chan struct{}, 1)}
+		l.names[name] = ll
+	}
+	ll.refs++
+	l.mu.Unlock()
+
+	select {
+	case ll.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.deref(name, ll)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// unlock lets the next caller waiting for name in. It does nothing if name
+// is not held.
+func (l *localLocks) unlock(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ll, ok := l.names[name]
+	if !ok {
+		return
+	}
+	select {
+	case <-ll.sem:
+		l.deref(name, ll)
+	default:
+	}
+}
+
+func (l *localLocks) deref(name string, ll *localLock) {
+	ll.refs--
+	if ll.refs == 0 {
+		delete(l.names, name)
+	}
+}
@@ -0,0 +1,21 @@
+package lockpattern
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrNotLocked = errors.New("lock not held")
+
+// Locker hands out named locks that are exclusive across every Locker of the
+// same backend, e.g. across processes sharing a MySQL server. A lock is
+// released by Unlock or, failing that, once its ttl has elapsed, so that a
+// crashed holder does not keep it forever.
+//
+// Goroutines sharing a Locker take turns as well: Lock waits for the caller
+// holding the name to Unlock it, even once its ttl has elapsed.
+type Locker interface {
+	Lock(ctx context.Context, name string, ttl time.Duration) error
+	Unlock(ctx context.Context, name string) error
+}
@@ -0,0 +1,83 @@
+package lockpattern
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryLocks is an in-memory lock table, for tests. Each Locker it returns
+// stands for a separate process.
+type MemoryLocks struct {
+	mu      sync.Mutex
+	held    map[string]memoryLock
+	changed chan struct{} // closed and replaced when a lock is released
+}
+
+type memoryLock struct {
+	owner   *memoryLocker
+	expires time.Time
+}
+
+type memoryLocker struct {
+	locks *MemoryLocks
+	local localLocks
+}
+
+func NewMemoryLocks() *MemoryLocks {
+	return &MemoryLocks{
+		held:    make(map[string]memoryLock),
+		changed: make(chan struct{}),
+	}
+}
+
+func (m *MemoryLocks) Locker() Locker {
+	return &memoryLocker{locks: m}
+}
+
+func (l *memoryLocker) Lock(ctx context.Context, name string, ttl time.Duration) error {
+	if err := l.local.lock(ctx, name); err != nil {
+		return err
+	}
+	m := l.locks
+	for {
+		m.mu.Lock()
+		now := time.Now()
+		held, ok := m.held[name]
+		if !ok || !now.Before(held.expires) {
+			m.held[name] = memoryLock{owner: l, expires: now.Add(ttl)}
+			m.mu.Unlock()
+			return nil
+		}
+		changed := m.changed
+		m.mu.Unlock()
+
+		timer := time.NewTimer(held.expires.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			l.local.unlock(name)
+			return ctx.Err()
+		case <-changed:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (l *memoryLocker) Unlock(ctx context.Context, name string) error {
+	// Even a lock lost to its ttl is handed to the next caller of l.
+	defer l.local.unlock(name)
+	m := l.locks
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	held, ok := m.held[name]
+	if !ok || held.owner != l || !time.Now().Before(held.expires) {
+		return fmt.Errorf("unlock %v: %w", name, ErrNotLocked)
+	}
+	delete(m.held, name)
+	close(m.changed)
+	m.changed = make(chan struct{})
+	return nil
+}
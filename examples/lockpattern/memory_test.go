@@ -0,0 +1,86 @@
+package lockpattern
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLocks_MutualExclusion(t *testing.T) {
+	locks := NewMemoryLocks()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	count := 0
+	const processes, N = 4, 100
+	for p := 0; p < processes; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			locker := locks.Locker()
+			for i := 0; i < N; i++ {
+				assert.NoError(t, locker.Lock(context.TODO(), "reading", time.Minute))
+				// Only one holder at a time, so TryLock always succeeds.
+				assert.True(t, mu.TryLock())
+				count++
+				mu.Unlock()
+				assert.NoError(t, locker.Unlock(context.TODO(), "reading"))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, processes*N, count)
+}
+
+func TestMemoryLocks_TTL(t *testing.T) {
+	locks := NewMemoryLocks()
+	crashed, other := locks.Locker(), locks.Locker()
+	assert.NoError(t, crashed.Lock(context.TODO(), "reading", 20*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, other.Lock(ctx, "reading", time.Minute), context.DeadlineExceeded)
+
+	// The holder never unlocks; the lock goes once its ttl has elapsed.
+	assert.NoError(t, other.Lock(context.TODO(), "reading", time.Minute))
+	assert.ErrorIs(t, crashed.Unlock(context.TODO(), "reading"), ErrNotLocked)
+	assert.NoError(t, other.Unlock(context.TODO(), "reading"))
+	assert.ErrorIs(t, other.Unlock(context.TODO(), "reading"), ErrNotLocked)
+}
+
+func TestMemoryLocks_SharedLocker(t *testing.T) {
+	locker := NewMemoryLocks().Locker()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	count := 0
+	const goroutines, N = 8, 50
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < N; i++ {
+				// Callers of one Locker wait for each other instead of
+				// finding the lock already held.
+				assert.NoError(t, locker.Lock(context.TODO(), "reading", time.Minute))
+				assert.True(t, mu.TryLock())
+				count++
+				mu.Unlock()
+				assert.NoError(t, locker.Unlock(context.TODO(), "reading"))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, goroutines*N, count)
+
+	// A caller giving up does not hold up the next one.
+	assert.NoError(t, locker.Lock(context.TODO(), "reading", time.Minute))
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, locker.Lock(ctx, "reading", time.Minute), context.DeadlineExceeded)
+	assert.NoError(t, locker.Unlock(context.TODO(), "reading"))
+	assert.NoError(t, locker.Lock(context.TODO(), "reading", time.Minute))
+	assert.NoError(t, locker.Unlock(context.TODO(), "reading"))
+	assert.Empty(t, locker.(*memoryLocker).local.names)
+}
@@ -0,0 +1,121 @@
+package lockpattern
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MysqlLocker uses MySQL named locks (GET_LOCK / RELEASE_LOCK). A named lock
+// belongs to a database session, so every held lock pins a connection of the
+// pool until it is released. MySQL locks have no expiry: the ttl is enforced
+// by closing the connection, which makes the server release the lock.
+type MysqlLocker struct {
+	db    *sql.DB
+	local localLocks
+	mu    sync.Mutex
+	held  map[string]*mysqlLock
+}
+
+type mysqlLock struct {
+	conn  *sql.Conn
+	timer *time.Timer
+}
+
+func NewMysqlLocker(db *sql.DB) *MysqlLocker {
+	return &MysqlLocker{
+		db:   db,
+		held: make(map[string]*mysqlLock),
+	}
+}
+
+func (l *MysqlLocker) Lock(ctx context.Context, name string, ttl time.Duration) error {
+	// Callers in this process queue here, so at most one of them at a time
+	// holds a connection waiting for GET_LOCK.
+	if err := l.local.lock(ctx, name); err != nil {
+		return err
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		l.local.unlock(name)
+		return fmt.Errorf("lock %v: %v", name, err)
+	}
+	// Wait in short rounds, so that ctx is checked between them.
+	for {
+		var acquired sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 1)", name).Scan(&acquired); err != nil {
+			// The query may have been cut short after the lock was granted.
+			discard(conn)
+			l.local.unlock(name)
+			return fmt.Errorf("lock %v: %v", name, err)
+		}
+		if acquired.Valid && acquired.Int64 == 1 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			conn.Close()
+			l.local.unlock(name)
+			return err
+		}
+	}
+
+	lock := &mysqlLock{conn: conn}
+	lock.timer = time.AfterFunc(ttl, func() {
+		l.expire(name, lock)
+	})
+	l.mu.Lock()
+	l.held[name] = lock
+	l.mu.Unlock()
+	return nil
+}
+
+// Unlock releases name. If the release fails, for instance because ctx is
+// done, the session is closed, which releases the lock on the server anyway.
+func (l *MysqlLocker) Unlock(ctx context.Context, name string) error {
+	defer l.local.unlock(name)
+	l.mu.Lock()
+	lock, ok := l.held[name]
+	delete(l.held, name)
+	l.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unlock %v: %w", name, ErrNotLocked)
+	}
+	lock.timer.Stop()
+
+	var released sql.NullInt64
+	if err := lock.conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", name).Scan(&released); err != nil {
+		discard(lock.conn)
+		return fmt.Errorf("unlock %v: %v", name, err)
+	}
+	lock.conn.Close()
+	if !released.Valid || released.Int64 != 1 {
+		return fmt.Errorf("unlock %v: %w", name, ErrNotLocked)
+	}
+	return nil
+}
+
+// expire drops a lock whose ttl has elapsed. Closing the session releases it
+// on the server.
+func (l *MysqlLocker) expire(name string, lock *mysqlLock) {
+	l.mu.Lock()
+	if l.held[name] != lock {
+		l.mu.Unlock()
+		return
+	}
+	delete(l.held, name)
+	l.mu.Unlock()
+	discard(lock.conn)
+}
+
+// discard closes the session of conn for real. sql.Conn.Close alone hands it
+// back to the pool, still holding its locks, so it is marked bad first.
+func discard(conn *sql.Conn) {
+	conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
@@ -14,6 +14,7 @@ import (
     "context"
     "database/sql"
     "fmt"
+    "rhzx3519/go-concurrency/examples/futurepattern"
 )
 
 type Counter struct {
@@ -24,33 +25,28 @@ type Counter struct {
 
 type Add1Param struct {
     Name  string
-    Count chan int
+    Count *futurepattern.Future[int]
 }
 
 type AddCounterParam struct {
     Name   string
-    Result chan CounterResult
-}
-
-type CounterResult struct {
-    Counter Counter
-    Err     error
+    Result *futurepattern.Future[Counter]
 }
 
 type QueryByNameParam struct {
     Name   string
-    Result chan CounterResult
+    Result *futurepattern.Future[Counter]
 }
 
 type UpdateCounterParam struct {
     ID     int64
     Count  int
-    Result chan CounterResult
+    Result *futurepattern.Future[Counter]
 }
 
 type DeleteByNameParam struct {
     Name   string
-    Result chan CounterResult
+    Result *futurepattern.Future[Counter]
 }
 
 func addCounter(param AddCounterParam, db *sql.DB) (int64, error) {
@@ -81,18 +77,18 @@ func addCounter(param AddCounterParam, db *sql.DB) (int64, error) {
     return id, nil
 }
 
-func queryByName(name string, db *sql.DB) (Counter, error) {
+func queryByName(ctx context.Context, name string, db *sql.DB) (Counter, error) {
     var counter Counter
 
     // Get a Tx for making transaction requests.
-    tx, err := db.BeginTx(context.TODO(), nil)
+    tx, err := db.BeginTx(ctx, nil)
     if err != nil {
         return counter, err
     }
     // Defer a rollback in case anything fails.
     defer tx.Rollback()
 
-    row := tx.QueryRow("SELECT id, name, count FROM counters WHERE name = ?", name)
+    row := tx.QueryRowContext(ctx, "SELECT id, name, count FROM counters WHERE name = ?", name)
     if err := row.Scan(&counter.ID, &counter.Name, &counter.Count); err != nil {
         if err == sql.ErrNoRows {
             return counter, fmt.Errorf("queryByName %v: no such counter", name)
@@ -0,0 +1,97 @@
+package mysqlpattern
+
+import (
+    "context"
+    "fmt"
+    "rhzx3519/go-concurrency/examples/cronpattern"
+    "rhzx3519/go-concurrency/examples/shardedmappattern"
+    "sort"
+    "sync/atomic"
+)
+
+// MemoryStore keeps the counters in memory. Counters are sharded by name, so
+// Add1 on distinct names does not serialize through a single goroutine the
+// way MysqlClient does.
+type MemoryStore struct {
+    counters *shardedmappattern.Map[string, Counter]
+    nextID   atomic.Int64
+}
+
+func NewMemoryStore(shards int) *MemoryStore {
+    return &MemoryStore{
+        counters: shardedmappattern.New[string, Counter](shards),
+    }
+}
+
+func (s *MemoryStore) AddCounter(name string) (Counter, error) {
+    exists := false
+    counter, _ := s.counters.Compute(name, func(old Counter, loaded bool) (Counter, bool) {
+        if loaded {
+            exists = true
+            return old, true
+        }
+        // Only a new counter takes an ID.
+        return Counter{ID: s.nextID.Add(1), Name: name}, true
+    })
+    if exists {
+        return Counter{}, fmt.Errorf("addCounter %v: counter exists", name)
+    }
+    return counter, nil
+}
+
+func (s *MemoryStore) Add1(name string) (int, error) {
+    counter, ok := s.counters.Compute(name, func(old Counter, loaded bool) (Counter, bool) {
+        if !loaded {
+            return old, false
+        }
+        old.Count++
+        return old, true
+    })
+    if !ok {
+        return 0, fmt.Errorf("add1 %v: no such counter", name)
+    }
+    return counter.Count, nil
+}
+
+func (s *MemoryStore) QueryByName(name string) (Counter, error) {
+    counter, ok := s.counters.Load(name)
+    if !ok {
+        return counter, fmt.Errorf("queryByName %v: no such counter", name)
+    }
+    return counter, nil
+}
+
+func (s *MemoryStore) DeleteByName(name string) error {
+    deleted := false
+    s.counters.Compute(name, func(old Counter, loaded bool) (Counter, bool) {
+        deleted = loaded
+        return old, false
+    })
+    if !deleted {
+        return fmt.Errorf("deleteByName %v: no such counter", name)
+    }
+    return nil
+}
+
+// Snapshot returns a copy of every counter, sorted by name.
+func (s *MemoryStore) Snapshot() []Counter {
+    var counters []Counter
+    s.counters.Range(func(_ string, counter Counter) bool {
+        counters = append(counters, counter)
+        return true
+    })
+    sort.Slice(counters, func(i, j int) bool {
+        return counters[i].Name < counters[j].Name
+    })
+    return counters
+}
+
+// ScheduleSnapshots hands a snapshot of the counters to save on the cron
+// schedule spec, e.g. to persist them in MySQL. A snapshot still being saved
+// when the next one is due is skipped.
+func (s *MemoryStore) ScheduleSnapshots(scheduler *cronpattern.Scheduler, spec string,
+    save func(ctx context.Context, counters []Counter)) (cronpattern.EntryID, error) {
+    return scheduler.Add(spec, func(ctx context.Context) {
+        save(ctx, s.Snapshot())
+    }, cronpattern.WithOverlap(cronpattern.Skip))
+}
@@ -0,0 +1,72 @@
+package mysqlpattern
+
+import (
+    "context"
+    "github.com/stretchr/testify/assert"
+    "rhzx3519/go-concurrency/examples/clockpattern"
+    "rhzx3519/go-concurrency/examples/cronpattern"
+    "strconv"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestMemoryStore_Add1(t *testing.T) {
+    store := NewMemoryStore(0)
+    const names, N = 10, 1000
+    for i := 0; i < names; i++ {
+        _, err := store.AddCounter("reading" + strconv.Itoa(i))
+        assert.NoError(t, err)
+    }
+    _, err := store.AddCounter("reading0")
+    assert.Error(t, err)
+    // A failed AddCounter does not use up an ID.
+    counter, err := store.AddCounter("writing")
+    assert.NoError(t, err)
+    assert.Equal(t, int64(names+1), counter.ID)
+
+    var wg sync.WaitGroup
+    for i := 0; i < N; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            store.Add1("reading" + strconv.Itoa(i%names))
+        }(i)
+    }
+    wg.Wait()
+
+    for i := 0; i < names; i++ {
+        counter, err := store.QueryByName("reading" + strconv.Itoa(i))
+        assert.NoError(t, err)
+        assert.Equal(t, N/names, counter.Count)
+    }
+
+    assert.NoError(t, store.DeleteByName("reading0"))
+    assert.Error(t, store.DeleteByName("reading0"))
+    _, err = store.Add1("reading0")
+    assert.Error(t, err)
+}
+
+func TestMemoryStore_ScheduleSnapshots(t *testing.T) {
+    store := NewMemoryStore(0)
+    store.AddCounter("writing")
+    store.AddCounter("reading")
+    store.Add1("reading")
+
+    clock := clockpattern.NewFake(time.Now())
+    scheduler := cronpattern.NewScheduler(clock, time.UTC)
+    snapshots := make(chan []Counter, 1)
+    _, err := store.ScheduleSnapshots(scheduler, "* * * * *", func(ctx context.Context, counters []Counter) {
+        snapshots <- counters
+    })
+    assert.NoError(t, err)
+    ctx, cancel := context.WithCancel(context.TODO())
+    defer cancel()
+    scheduler.Run(ctx)
+
+    clock.Advance(time.Minute)
+    snapshot := <-snapshots
+    assert.Len(t, snapshot, 2)
+    assert.Equal(t, "reading", snapshot[0].Name)
+    assert.Equal(t, 1, snapshot[0].Count)
+}
@@ -6,10 +6,24 @@ import (
     "errors"
     "fmt"
     "github.com/go-sql-driver/mysql"
-    "log"
+    "io"
+    "log/slog"
     "os"
+    "rhzx3519/go-concurrency/examples/budgetpattern"
+    "rhzx3519/go-concurrency/examples/futurepattern"
+    "rhzx3519/go-concurrency/examples/hedgepattern"
+    "rhzx3519/go-concurrency/examples/lockpattern"
+    "rhzx3519/go-concurrency/examples/semaphorepattern"
+    "rhzx3519/go-concurrency/examples/shutdownpattern"
+    "rhzx3519/go-concurrency/examples/singleflightpattern"
+    "rhzx3519/go-concurrency/examples/watchdogpattern"
+    "sync"
+    "sync/atomic"
+    "time"
 )
 
+var ErrClosed = errors.New("mysql client closed")
+
 type InsertOp struct {
     Table  string
     Query  string
@@ -42,17 +56,113 @@ type MysqlClient struct {
     updateCounterParam chan UpdateCounterParam
     deleteByNameParam  chan DeleteByNameParam
     insertOpStream     chan InsertOp
+    bulkhead           *semaphorepattern.Bulkhead
+    bulkheadWait       time.Duration
+    locker             lockpattern.Locker
+    replicas           []*sql.DB
+    hedgeDelay         hedgepattern.Delay
+    budget             *budgetpattern.Budget
+    nextReplica        atomic.Uint64
+    queryGroup         *singleflightpattern.Group[string, Counter]
+    watchdog           *watchdogpattern.Watchdog
+    logger             *slog.Logger
+    cancel             context.CancelFunc
+    done               chan struct{} // closed when the actor loop exits
+    // mu guards stopped and inFlight, so that no call can enter once
+    // Shutdown has seen the count.
+    mu       sync.Mutex
+    stopped  bool
+    inFlight int
+    idle     chan struct{} // closed once stopped with no call in flight
+}
+
+type Option func(*MysqlClient)
+
+// WithBulkhead caps the in-flight calls per counter name, so one hot counter
+// cannot take all the concurrency in front of the client. A call waits at
+// most maxWait for a slot, then fails with semaphorepattern.ErrBulkheadFull;
+// with a maxWait of zero it fails right away.
+func WithBulkhead(limit int64, maxWait time.Duration) Option {
+    return func(c *MysqlClient) {
+        c.bulkhead = semaphorepattern.NewBulkhead(limit)
+        c.bulkheadWait = maxWait
+    }
+}
+
+// WithQuerySharing keeps serving a QueryByName result to other callers for d
+// after it returns. By default only concurrent reads share a result.
+func WithQuerySharing(d time.Duration) Option {
+    return func(c *MysqlClient) {
+        c.queryGroup = singleflightpattern.NewGroup[string, Counter](d)
+    }
+}
+
+// WithReplicas lets QueryReplica read from replicas. A read that is slower
+// than delay is hedged against the next replica. A nil delay hedges reads
+// slower than the 95th percentile of recent ones.
+func WithReplicas(delay hedgepattern.Delay, replicas ...*sql.DB) Option {
+    return func(c *MysqlClient) {
+        if delay == nil {
+            delay = hedgepattern.NewPercentile(95, 100, 100*time.Millisecond)
+        }
+        c.hedgeDelay = delay
+        c.replicas = replicas
+    }
+}
+
+// WithBudget makes QueryReplica fail fast, without touching the replicas,
+// when its context has less time left than a replica read usually takes.
+func WithBudget(budget *budgetpattern.Budget) Option {
+    return func(c *MysqlClient) {
+        c.budget = budget
+    }
+}
+
+// lockTTL bounds how long a crashed process can hold a counter's lock.
+const lockTTL = 10 * time.Second
+
+// WithLocker makes Add1 hold a per-counter lock, so that its read-modify-write
+// is also serialized against other processes, not only within this one.
+func WithLocker(locker lockpattern.Locker) Option {
+    return func(c *MysqlClient) {
+        c.locker = locker
+    }
+}
+
+// WithWatchdog reports actor loop iterations that get stuck, such as a reply
+// that nobody receives.
+func WithWatchdog(w *watchdogpattern.Watchdog) Option {
+    return func(c *MysqlClient) {
+        c.watchdog = w
+    }
+}
+
+// WithLogger logs the client's lifecycle and every call, with its op, counter
+// name and latency. Failed calls are logged at error level, the others at
+// debug level. By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+    return func(c *MysqlClient) {
+        c.logger = logger
+    }
 }
 
-func NewMysqlClient() *MysqlClient {
-    return &MysqlClient{
+func NewMysqlClient(opts ...Option) *MysqlClient {
+    c := &MysqlClient{
         add1Stream:         make(chan Add1Param),
         addCounterParam:    make(chan AddCounterParam),
         queryByNameParam:   make(chan QueryByNameParam),
         updateCounterParam: make(chan UpdateCounterParam),
         deleteByNameParam:  make(chan DeleteByNameParam),
         insertOpStream:     make(chan InsertOp),
+        queryGroup:         singleflightpattern.NewGroup[string, Counter](0),
+        done:               make(chan struct{}),
+        idle:               make(chan struct{}),
+        logger:             slog.New(slog.NewTextHandler(io.Discard, nil)),
     }
+    for _, opt := range opts {
+        opt(c)
+    }
+    return c
 }
 
 func (c *MysqlClient) Run(ctx context.Context) (err error) {
@@ -74,93 +184,373 @@ func (c *MysqlClient) Run(ctx context.Context) (err error) {
     if pingErr != nil {
         return pingErr
     }
-    fmt.Println("Mysql Connected...")
+    c.logger.Info("mysql connected", slog.String("addr", cfg.Addr))
 
+    ctx, c.cancel = context.WithCancel(ctx)
+    c.watchdog.Run(ctx)
     go func() {
         defer c.exit()
+        c.watchdog.Label(ctx)
         for {
             select {
             case param := <-c.addCounterParam:
+                c.watchdog.Begin()
                 id, err := addCounter(param, c.db)
                 if err != nil {
-                    param.Result <- CounterResult{Err: err}
+                    param.Result.Complete(Counter{}, err)
                 } else {
-                    param.Result <- CounterResult{Counter: Counter{ID: id, Name: param.Name}}
+                    param.Result.Complete(Counter{ID: id, Name: param.Name}, nil)
                 }
             case param := <-c.queryByNameParam:
-                counter, err := queryByName(param.Name, c.db)
-                param.Result <- CounterResult{Counter: counter, Err: err}
+                c.watchdog.Begin()
+                param.Result.Complete(queryByName(ctx, param.Name, c.db))
             case param := <-c.updateCounterParam:
+                c.watchdog.Begin()
                 err := updateCounter(param.ID, param.Count, c.db)
-                param.Result <- CounterResult{Err: err}
+                param.Result.Complete(Counter{}, err)
             case param := <-c.deleteByNameParam:
+                c.watchdog.Begin()
                 err := deleteByName(param.Name, c.db)
-                param.Result <- CounterResult{Err: err}
+                param.Result.Complete(Counter{}, err)
             case param := <-c.add1Stream:
-                count, err := c.doSomeSql(param.Name)
-                if err != nil {
-                    log.Fatalln(err)
-                }
-                param.Count <- count
+                c.watchdog.Begin()
+                param.Count.Complete(c.doSomeSql(param.Name))
             case <-ctx.Done():
                 return
             }
+            c.watchdog.End()
         }
     }()
 
     return
 }
 
+// Add1 is Add1Context without a deadline. Failures are only logged.
 func (c *MysqlClient) Add1(name string) int {
+    count, _ := c.Add1Context(context.Background(), name)
+    return count
+}
+
+// Add1Context increments the counter name and returns its new count. With
+// WithLocker, an error releasing the lock is returned along with the count.
+func (c *MysqlClient) Add1Context(ctx context.Context, name string) (count int, err error) {
+    start := time.Now()
+    defer func() { c.logCall(ctx, "Add1", name, start, err) }()
+    // Writes invalidate any shared QueryByName result.
+    defer c.queryGroup.Forget(name)
+    release, err := c.acquire(ctx, name)
+    if err != nil {
+        return 0, err
+    }
+    defer release()
+    if !c.enter() {
+        return 0, ErrClosed
+    }
+    defer c.leave()
     param := Add1Param{
         Name:  name,
-        Count: make(chan int),
+        Count: futurepattern.New[int](),
+    }
+    sent := false
+    if c.locker != nil {
+        key := "counter/" + name
+        if err := c.locker.Lock(ctx, key, lockTTL); err != nil {
+            return 0, err
+        }
+        defer func() {
+            if sent && !isDone(param.Count.Done()) {
+                // The caller gave up, but the actor may still be in the
+                // middle of the read-modify-write.
+                c.unlockAfter(ctx, key, param.Count.Done())
+                return
+            }
+            // A failed unlock may mean the lock expired while we held it,
+            // and another process got to the counter meanwhile.
+            err = errors.Join(err, c.locker.Unlock(context.WithoutCancel(ctx), key))
+        }()
     }
-    defer close(param.Count)
 
-    c.add1Stream <- param
-    return <-param.Count
+    if err := send(ctx, c.done, c.add1Stream, param); err != nil {
+        return 0, err
+    }
+    sent = true
+    return param.Count.Await(ctx)
 }
 
-func (c *MysqlClient) QueryByName(name string) Counter {
-    param := QueryByNameParam{
-        Name:   name,
-        Result: make(chan CounterResult),
+// unlockAfter releases key once done is closed, without making the caller
+// wait. The release counts as a call in flight, so that Shutdown waits for
+// it. It must be called by a call that is still in flight.
+func (c *MysqlClient) unlockAfter(ctx context.Context, key string, done <-chan struct{}) {
+    c.mu.Lock()
+    c.inFlight++
+    c.mu.Unlock()
+    ctx = context.WithoutCancel(ctx)
+    go func() {
+        defer c.leave()
+        <-done
+        if err := c.locker.Unlock(ctx, key); err != nil {
+            c.logger.LogAttrs(ctx, slog.LevelError, "unlock failed", slog.String("key", key),
+                slog.Any("err", err))
+        }
+    }()
+}
+
+func isDone(done <-chan struct{}) bool {
+    select {
+    case <-done:
+        return true
+    default:
+        return false
     }
-    defer close(param.Result)
+}
+
+// QueryByName is QueryByNameContext without a deadline. Failures are only
+// logged.
+func (c *MysqlClient) QueryByName(name string) Counter {
+    counter, _ := c.QueryByNameContext(context.Background(), name)
+    return counter
+}
+
+// QueryByNameContext coalesces concurrent reads of the same name into one
+// trip through the actor. Each caller waits no longer than its own ctx
+// allows; the trip is canceled once every caller has given up.
+func (c *MysqlClient) QueryByNameContext(ctx context.Context, name string) (Counter, error) {
+    start := time.Now()
+    counter, err, _ := c.queryGroup.Do(ctx, name, func(ctx context.Context) (Counter, error) {
+        release, err := c.acquire(ctx, name)
+        if err != nil {
+            return Counter{}, err
+        }
+        defer release()
+        if !c.enter() {
+            return Counter{}, ErrClosed
+        }
+        defer c.leave()
+
+        param := QueryByNameParam{
+            Name:   name,
+            Result: futurepattern.New[Counter](),
+        }
+        if err := send(ctx, c.done, c.queryByNameParam, param); err != nil {
+            return Counter{}, err
+        }
+        return param.Result.Await(ctx)
+    })
+    c.logCall(ctx, "QueryByName", name, start, err)
+    return counter, err
+}
 
-    c.queryByNameParam <- param
-    return (<-param.Result).Counter
+// QueryReplica reads a counter from the replicas without going through the
+// actor. Each replica is tried at most once, the first answer wins.
+func (c *MysqlClient) QueryReplica(ctx context.Context, name string) (Counter, error) {
+    if len(c.replicas) == 0 {
+        return Counter{}, fmt.Errorf("QueryReplica %v: no replicas", name)
+    }
+    start := time.Now()
+    first := c.nextReplica.Add(1)
+    var attempts atomic.Uint64
+    read := func(ctx context.Context) (Counter, error) {
+        return hedgepattern.Hedge(ctx, c.hedgeDelay, len(c.replicas)-1, func(ctx context.Context) (Counter, error) {
+            i := (first + attempts.Add(1)) % uint64(len(c.replicas))
+            return queryByName(ctx, name, c.replicas[i])
+        })
+    }
+    var counter Counter
+    var err error
+    if c.budget != nil {
+        counter, err = budgetpattern.Call(ctx, c.budget, "QueryReplica", read)
+    } else {
+        counter, err = read(ctx)
+    }
+    c.logCall(ctx, "QueryReplica", name, start, err)
+    return counter, err
 }
 
+// AddCounter is AddCounterContext without a deadline. Failures are only
+// logged.
 func (c *MysqlClient) AddCounter(name string) Counter {
+    counter, _ := c.AddCounterContext(context.Background(), name)
+    return counter
+}
+
+func (c *MysqlClient) AddCounterContext(ctx context.Context, name string) (counter Counter, err error) {
+    start := time.Now()
+    defer func() { c.logCall(ctx, "AddCounter", name, start, err) }()
+    // Writes invalidate any shared QueryByName result.
+    defer c.queryGroup.Forget(name)
+    release, err := c.acquire(ctx, name)
+    if err != nil {
+        return Counter{}, err
+    }
+    defer release()
+    if !c.enter() {
+        return Counter{}, ErrClosed
+    }
+    defer c.leave()
+
     param := AddCounterParam{
         Name:   name,
-        Result: make(chan CounterResult),
+        Result: futurepattern.New[Counter](),
     }
-    defer close(param.Result)
-
-    c.addCounterParam <- param
-    return (<-param.Result).Counter
+    if err := send(ctx, c.done, c.addCounterParam, param); err != nil {
+        return Counter{}, err
+    }
+    return param.Result.Await(ctx)
 }
 
+// DeleteByName is DeleteByNameContext without a deadline.
 func (c *MysqlClient) DeleteByName(name string) error {
+    return c.DeleteByNameContext(context.Background(), name)
+}
+
+func (c *MysqlClient) DeleteByNameContext(ctx context.Context, name string) (err error) {
+    start := time.Now()
+    defer func() { c.logCall(ctx, "DeleteByName", name, start, err) }()
+    // Writes invalidate any shared QueryByName result.
+    defer c.queryGroup.Forget(name)
+    release, err := c.acquire(ctx, name)
+    if err != nil {
+        return err
+    }
+    defer release()
+    if !c.enter() {
+        return ErrClosed
+    }
+    defer c.leave()
+
     param := DeleteByNameParam{
         Name:   name,
-        Result: make(chan CounterResult),
+        Result: futurepattern.New[Counter](),
+    }
+    if err := send(ctx, c.done, c.deleteByNameParam, param); err != nil {
+        return err
     }
-    defer close(param.Result)
+    _, err = param.Result.Await(ctx)
+    return err
+}
 
-    c.deleteByNameParam <- param
-    return (<-param.Result).Err
+// send hands param to the actor, unless it has stopped or ctx is done first.
+func send[P any](ctx context.Context, done <-chan struct{}, stream chan<- P, param P) error {
+    select {
+    case stream <- param:
+        return nil
+    case <-done:
+        return ErrClosed
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
+
+// acquire takes a bulkhead slot for name and returns its release func. It
+// waits for the slot at most c.bulkheadWait, and no longer than ctx allows.
+func (c *MysqlClient) acquire(ctx context.Context, name string) (release func(), err error) {
+    if c.bulkhead == nil {
+        return func() {}, nil
+    }
+    if c.bulkheadWait <= 0 {
+        return c.bulkhead.TryAcquire(name)
+    }
+    waitCtx, cancel := context.WithTimeout(ctx, c.bulkheadWait)
+    defer cancel()
+    release, err = c.bulkhead.Acquire(waitCtx, name)
+    if err != nil && ctx.Err() == nil {
+        // Our own wait ran out, not the caller's.
+        return nil, semaphorepattern.ErrBulkheadFull
+    }
+    return release, err
+}
+
+// Shutdown stops accepting calls and waits for the in-flight ones to drain
+// before stopping the actor and closing the database. If ctx is done first,
+// the actor is stopped anyway and ctx.Err() is returned.
+func (c *MysqlClient) Shutdown(ctx context.Context) error {
+    c.mu.Lock()
+    if !c.stopped {
+        c.stopped = true
+        if c.inFlight == 0 {
+            close(c.idle)
+        }
+    }
+    c.mu.Unlock()
+    if c.cancel == nil {
+        // Never ran.
+        return nil
+    }
+
+    var err error
+    select {
+    case <-c.idle:
+    case <-ctx.Done():
+        err = ctx.Err()
+    }
+
+    c.cancel()
+    select {
+    case <-c.done:
+    case <-ctx.Done():
+        err = ctx.Err()
+    }
+    return err
+}
+
+// InFlight reports the number of calls waiting for the actor.
+func (c *MysqlClient) InFlight() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.inFlight
+}
+
+// Component describes the client to a shutdownpattern.Coordinator, so that
+// it is shut down after the components that depend on it and reports the
+// calls still in flight.
+func (c *MysqlClient) Component(name string, dependsOn ...string) shutdownpattern.Component {
+    return shutdownpattern.Component{
+        Name:      name,
+        DependsOn: dependsOn,
+        Shutdown:  c.Shutdown,
+        InFlight:  c.InFlight,
+    }
+}
+
+// enter registers an in-flight call, it fails once Shutdown has started.
+func (c *MysqlClient) enter() bool {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.stopped {
+        return false
+    }
+    c.inFlight++
+    return true
+}
+
+func (c *MysqlClient) leave() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.inFlight--
+    if c.stopped && c.inFlight == 0 {
+        close(c.idle)
+    }
 }
 
 func (c *MysqlClient) exit() {
-    close(c.add1Stream)
+    // The request streams are left open: callers may still be sending on
+    // them, they give up on c.done instead.
+    close(c.done)
     if err := c.db.Close(); err != nil {
-        log.Panicln(err)
+        c.logger.Error("mysql close", slog.Any("err", err))
+    }
+    c.logger.Info("mysql disconnected")
+}
+
+func (c *MysqlClient) logCall(ctx context.Context, op, name string, start time.Time, err error) {
+    latency := time.Since(start)
+    if err != nil {
+        c.logger.LogAttrs(ctx, slog.LevelError, "call failed", slog.String("op", op),
+            slog.String("counter", name), slog.Duration("latency", latency), slog.Any("err", err))
+        return
     }
-    fmt.Println("Mysql Disconnected...")
+    c.logger.LogAttrs(ctx, slog.LevelDebug, "call", slog.String("op", op),
+        slog.String("counter", name), slog.Duration("latency", latency))
 }
 
 func (c *MysqlClient) doSomeSql(name string) (int, error) {
@@ -6,13 +6,17 @@ import (
     "fmt"
     "github.com/go-sql-driver/mysql"
     "github.com/stretchr/testify/assert"
-    "log"
+    "rhzx3519/go-concurrency/examples/barrierpattern"
+    "rhzx3519/go-concurrency/examples/lockpattern"
+    "rhzx3519/go-concurrency/examples/semaphorepattern"
+    "rhzx3519/go-concurrency/examples/shutdownpattern"
     "sync"
     "testing"
     "time"
 )
 
 func TestMysqlClient_Run(t *testing.T) {
+    connect(t)
     client := NewMysqlClient()
     ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
     defer cancel()
@@ -22,15 +26,19 @@ func TestMysqlClient_Run(t *testing.T) {
     client.AddCounter(COUNTER_NAME)
 
     var wg sync.WaitGroup
+    // Start every goroutine at the same moment.
+    start := barrierpattern.NewCountDownLatch(1)
     const N = 10000
     for i := 0; i < N; i++ {
         wg.Add(1)
         go func() {
             defer wg.Done()
+            start.Wait(context.TODO())
             client.Add1(COUNTER_NAME)
         }()
     }
 
+    start.CountDown()
     wg.Wait()
 
     count := client.QueryByName(COUNTER_NAME).Count
@@ -38,21 +46,22 @@ func TestMysqlClient_Run(t *testing.T) {
 }
 
 var (
-    db     *sql.DB
-    client *MysqlClient
+    connectOnce sync.Once
+    db          *sql.DB
+    connectErr  error
 )
 
-func init() {
-    var err error
-    db, err = initConnection()
-    if err != nil {
-        log.Fatalln(err)
+// connect skips the test when there is no MySQL to run it against, so that
+// the tests that do not need one still run.
+func connect(t testing.TB) *sql.DB {
+    t.Helper()
+    connectOnce.Do(func() {
+        db, connectErr = initConnection()
+    })
+    if connectErr != nil {
+        t.Skipf("mysql unavailable: %v", connectErr)
     }
-
-    client = NewMysqlClient()
-    ctx, _ := context.WithTimeout(context.TODO(), time.Minute)
-    //defer cancel()
-    client.Run(ctx)
+    return db
 }
 
 func initConnection() (*sql.DB, error) {
@@ -105,6 +114,12 @@ func add1Transaction(name string, db *sql.DB) (int, error) {
 }
 
 func BenchmarkMysqlClient_Add1(b *testing.B) {
+    db := connect(b)
+    client := NewMysqlClient()
+    ctx, cancel := context.WithTimeout(context.TODO(), time.Minute)
+    defer cancel()
+    client.Run(ctx)
+
     b.Run("transaction bench", func(b *testing.B) {
         for i := 0; i < b.N; i++ {
             add1Transaction("reading", db)
@@ -117,3 +132,138 @@ func BenchmarkMysqlClient_Add1(b *testing.B) {
         }
     })
 }
+
+func TestMysqlClient_Bulkhead(t *testing.T) {
+    // No waiting: the second caller is turned away.
+    client := NewMysqlClient(WithBulkhead(1, 0))
+    release, err := client.acquire(context.TODO(), "reading")
+    assert.NoError(t, err)
+    _, err = client.acquire(context.TODO(), "reading")
+    assert.ErrorIs(t, err, semaphorepattern.ErrBulkheadFull)
+    // Other counters have their own slots.
+    other, err := client.acquire(context.TODO(), "writing")
+    assert.NoError(t, err)
+    other()
+    release()
+
+    // A bounded wait, cut short by the caller's ctx if that comes first.
+    client = NewMysqlClient(WithBulkhead(1, 20*time.Millisecond))
+    release, err = client.acquire(context.TODO(), "reading")
+    assert.NoError(t, err)
+    defer release()
+    _, err = client.acquire(context.TODO(), "reading")
+    assert.ErrorIs(t, err, semaphorepattern.ErrBulkheadFull)
+    ctx, cancel := context.WithTimeout(context.TODO(), time.Millisecond)
+    defer cancel()
+    _, err = client.acquire(ctx, "reading")
+    assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestMysqlClient_Shutdown(t *testing.T) {
+    client := NewMysqlClient()
+    // Stand in for Run, which needs a database.
+    var ctx context.Context
+    ctx, client.cancel = context.WithCancel(context.TODO())
+    go func() {
+        <-ctx.Done()
+        close(client.done)
+    }()
+
+    assert.True(t, client.enter())
+    coordinator := shutdownpattern.NewCoordinator()
+    assert.NoError(t, coordinator.Register(client.Component("mysql client")))
+    reportStream := make(chan shutdownpattern.Report)
+    go func() {
+        report, _ := coordinator.Shutdown(context.TODO())
+        reportStream <- report
+    }()
+
+    // Once Shutdown has started no call gets in, and it waits for the one
+    // in flight.
+    assert.Eventually(t, func() bool { return !client.enter() }, time.Second, time.Millisecond)
+    select {
+    case <-reportStream:
+        t.Fatal("shutdown did not wait for the call in flight")
+    case <-time.After(20 * time.Millisecond):
+    }
+    client.leave()
+    report := <-reportStream
+    assert.NoError(t, report.Err())
+    assert.Equal(t, 0, report[0].InFlight)
+}
+
+func TestMysqlClient_Add1WithLocker(t *testing.T) {
+    // Two processes incrementing one counter, each with a fake actor whose
+    // read-modify-write is only safe under the lock.
+    locks := lockpattern.NewMemoryLocks()
+    var mu sync.Mutex
+    count := 0
+    newClient := func() *MysqlClient {
+        client := NewMysqlClient(WithLocker(locks.Locker()))
+        go func() {
+            for param := range client.add1Stream {
+                go func(param Add1Param) {
+                    mu.Lock()
+                    read := count
+                    mu.Unlock()
+                    time.Sleep(10 * time.Microsecond)
+                    mu.Lock()
+                    count = read + 1
+                    mu.Unlock()
+                    param.Count.Complete(read+1, nil)
+                }(param)
+            }
+        }()
+        return client
+    }
+    clients := []*MysqlClient{newClient(), newClient()}
+
+    var wg sync.WaitGroup
+    const N = 50
+    for _, client := range clients {
+        for i := 0; i < N; i++ {
+            wg.Add(1)
+            go func(client *MysqlClient) {
+                defer wg.Done()
+                _, err := client.Add1Context(context.TODO(), "reading")
+                assert.NoError(t, err)
+            }(client)
+        }
+    }
+    wg.Wait()
+    assert.Equal(t, len(clients)*N, count)
+}
+
+func TestMysqlClient_Add1WithLockerCallerGivesUp(t *testing.T) {
+    // The first caller gives up while its fake actor is still in the
+    // read-modify-write. The lock must stay held until the actor is done.
+    locks := lockpattern.NewMemoryLocks()
+    var mu sync.Mutex
+    count := 0
+    newClient := func(work time.Duration) *MysqlClient {
+        client := NewMysqlClient(WithLocker(locks.Locker()))
+        go func() {
+            for param := range client.add1Stream {
+                mu.Lock()
+                read := count
+                mu.Unlock()
+                time.Sleep(work)
+                mu.Lock()
+                count = read + 1
+                mu.Unlock()
+                param.Count.Complete(read+1, nil)
+            }
+        }()
+        return client
+    }
+    slow, fast := newClient(100*time.Millisecond), newClient(0)
+
+    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+    defer cancel()
+    _, err := slow.Add1Context(ctx, "reading")
+    assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+    got, err := fast.Add1Context(context.Background(), "reading")
+    assert.NoError(t, err)
+    assert.Equal(t, 2, got)
+}
@@ -50,7 +50,7 @@ func createQuery(q interface{}) {
     fmt.Println("unsupported type")
 }
 
-func ExampleRefect_parseAny() {
+func Example_createQuery() {
     o := order{
         ordId:      456,
         customerId: 56,
@@ -68,6 +68,7 @@ func ExampleRefect_parseAny() {
     i := 90
     createQuery(i)
     // Output:
-    //
-
+    // insert into order values(456, 56)
+    // insert into employee values("Naveen", 565, "Coimbatore", 90000, "India")
+    // unsupported type
 }
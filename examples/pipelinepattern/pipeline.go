@@ -0,0 +1,131 @@
+package pipelinepattern
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"rhzx3519/go-concurrency/examples"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrStarted = errors.New("pipeline already started")
+
+type StageFunc func(ctx context.Context, v interface{}) (interface{}, error)
+
+// Pipeline chains stages, each one a pool of workers reading from its own
+// buffered input stream. A value for which a stage returns an error is
+// dropped and counted.
+type Pipeline struct {
+	name    string
+	stages  []*stage
+	logger  *slog.Logger
+	started atomic.Bool
+}
+
+type stage struct {
+	name      string
+	workers   int
+	inStream  chan interface{}
+	fn        StageFunc
+	processed atomic.Int64
+	errors    atomic.Int64
+}
+
+func New(name string) *Pipeline {
+	return &Pipeline{
+		name:   name,
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// Logger logs every value a stage processes, with the stage as op and its
+// latency. Dropped values are logged at warn level, the others at debug
+// level. By default nothing is logged.
+func (p *Pipeline) Logger(logger *slog.Logger) *Pipeline {
+	p.logger = logger
+	return p
+}
+
+// Stage appends a stage running fn on workers goroutines, with an input
+// buffer of size buffer.
+func (p *Pipeline) Stage(name string, workers, buffer int, fn StageFunc) *Pipeline {
+	p.stages = append(p.stages, &stage{
+		name:     name,
+		workers:  max(1, workers),
+		inStream: make(chan interface{}, buffer),
+		fn:       fn,
+	})
+	return p
+}
+
+// Run feeds the values of valStream through the stages. The returned stream
+// is closed once valStream is closed and drained, or ctx is done. A pipeline
+// runs only once: the streams between its stages are closed when it stops,
+// so a second Run fails with ErrStarted.
+func (p *Pipeline) Run(ctx context.Context, valStream <-chan interface{}) (<-chan interface{}, error) {
+	if !p.started.CompareAndSwap(false, true) {
+		return nil, ErrStarted
+	}
+	outStream := make(chan interface{})
+	if len(p.stages) == 0 {
+		go forward(ctx, valStream, outStream)
+		return outStream, nil
+	}
+
+	go forward(ctx, valStream, p.stages[0].inStream)
+	for i, s := range p.stages {
+		next := outStream
+		if i+1 < len(p.stages) {
+			next = p.stages[i+1].inStream
+		}
+		s.run(ctx, next, p.logger.With(slog.String("pipeline", p.name)))
+	}
+	return outStream, nil
+}
+
+func (s *stage) run(ctx context.Context, outStream chan<- interface{}, logger *slog.Logger) {
+	var wg sync.WaitGroup
+	wg.Add(s.workers)
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for v := range examples.OrDone(ctx.Done(), s.inStream) {
+				start := time.Now()
+				out, err := s.fn(ctx, v)
+				s.processed.Add(1)
+				if err != nil {
+					s.errors.Add(1)
+					logger.LogAttrs(ctx, slog.LevelWarn, "value dropped", slog.String("op", s.name),
+						slog.Duration("latency", time.Since(start)), slog.Any("err", err))
+					continue
+				}
+				logger.LogAttrs(ctx, slog.LevelDebug, "value processed", slog.String("op", s.name),
+					slog.Duration("latency", time.Since(start)))
+				select {
+				case outStream <- out:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outStream)
+	}()
+}
+
+// forward copies valStream to outStream and closes it.
+func forward(ctx context.Context, valStream <-chan interface{}, outStream chan<- interface{}) {
+	defer close(outStream)
+	for v := range examples.OrDone(ctx.Done(), valStream) {
+		select {
+		case outStream <- v:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package pipelinepattern
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func generate(n int) <-chan interface{} {
+	valStream := make(chan interface{})
+	go func() {
+		defer close(valStream)
+		for i := 0; i < n; i++ {
+			valStream <- i
+		}
+	}()
+	return valStream
+}
+
+func TestPipeline_Run(t *testing.T) {
+	p := New("numbers").
+		Stage("double", 4, 8, func(ctx context.Context, v interface{}) (interface{}, error) {
+			return v.(int) * 2, nil
+		}).
+		Stage("keep multiples of 4", 2, 0, func(ctx context.Context, v interface{}) (interface{}, error) {
+			if v.(int)%4 != 0 {
+				return nil, errors.New("dropped")
+			}
+			return v, nil
+		})
+
+	outStream, err := p.Run(context.TODO(), generate(100))
+	assert.NoError(t, err)
+	var got []int
+	for v := range outStream {
+		got = append(got, v.(int))
+	}
+	sort.Ints(got)
+	assert.Len(t, got, 50)
+	assert.Equal(t, 0, got[0])
+	assert.Equal(t, 196, got[len(got)-1])
+
+	topology := p.Topology()
+	assert.Equal(t, int64(100), topology.Stages[0].Processed)
+	assert.Equal(t, int64(100), topology.Stages[1].Processed)
+	assert.Equal(t, int64(50), topology.Stages[1].Errors)
+
+	_, err = p.Run(context.TODO(), generate(100))
+	assert.ErrorIs(t, err, ErrStarted)
+}
+
+func TestPipeline_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	p := New("endless").Stage("id", 2, 1, func(ctx context.Context, v interface{}) (interface{}, error) {
+		return v, nil
+	})
+	valStream := make(chan interface{})
+	outStream, _ := p.Run(ctx, valStream)
+	valStream <- 1
+	assert.Equal(t, 1, <-outStream)
+	cancel()
+	for range outStream {
+	}
+}
+
+func TestPipeline_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	p := New("numbers").
+		Logger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))).
+		Stage("keep even", 2, 0, func(ctx context.Context, v interface{}) (interface{}, error) {
+			if v.(int)%2 != 0 {
+				return nil, errors.New("odd")
+			}
+			return v, nil
+		})
+	outStream, _ := p.Run(context.TODO(), generate(10))
+	for range outStream {
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Contains(t, lines[0], `msg="value dropped" pipeline=numbers op="keep even" latency=`)
+	assert.Contains(t, lines[0], "err=odd")
+}
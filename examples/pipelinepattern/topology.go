@@ -0,0 +1,82 @@
+package pipelinepattern
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Topology is a snapshot of a running pipeline.
+type Topology struct {
+	Name   string      `json:"name"`
+	Stages []StageInfo `json:"stages"`
+}
+
+type StageInfo struct {
+	Name    string `json:"name"`
+	Workers int    `json:"workers"`
+	// Buffer is the capacity of the stage's input stream and Depth the
+	// number of values waiting in it. A full buffer means the stage cannot
+	// keep up: that is where backpressure builds up.
+	Buffer    int   `json:"buffer"`
+	Depth     int   `json:"depth"`
+	Processed int64 `json:"processed"`
+	Errors    int64 `json:"errors"`
+}
+
+func (p *Pipeline) Topology() Topology {
+	t := Topology{Name: p.name, Stages: make([]StageInfo, 0, len(p.stages))}
+	for _, s := range p.stages {
+		t.Stages = append(t.Stages, StageInfo{
+			Name:      s.name,
+			Workers:   s.workers,
+			Buffer:    cap(s.inStream),
+			Depth:     len(s.inStream),
+			Processed: s.processed.Load(),
+			Errors:    s.errors.Load(),
+		})
+	}
+	return t
+}
+
+func (t Topology) JSON() ([]byte, error) {
+	return json.MarshalIndent(t, "", "  ")
+}
+
+// DOT renders the topology for Graphviz. Edges are labeled with the depth of
+// the stream they feed, and stages with a full input buffer are filled red.
+func (t Topology) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph \"%s\" {\n", dotEscape(t.Name))
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	b.WriteString("  \"source\" [shape=circle];\n")
+	for _, s := range t.Stages {
+		attrs := ""
+		if s.Buffer > 0 && s.Depth >= s.Buffer {
+			attrs = ", style=filled, fillcolor=\"#f4a6a6\""
+		}
+		name := dotEscape(s.Name)
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\\nworkers=%d processed=%d errors=%d\"%s];\n",
+			name, name, s.Workers, s.Processed, s.Errors, attrs)
+	}
+	b.WriteString("  \"sink\" [shape=circle];\n")
+
+	prev := "source"
+	for _, s := range t.Stages {
+		name := dotEscape(s.Name)
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [label=\"%d/%d\"];\n", prev, name, s.Depth, s.Buffer)
+		prev = name
+	}
+	fmt.Fprintf(&b, "  \"%s\" -> \"sink\";\n", prev)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotEscaper escapes a name for a DOT quoted string. Unlike Go's %q it leaves
+// non-ASCII alone, DOT files are UTF-8.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", "")
+
+func dotEscape(s string) string {
+	return dotEscaper.Replace(s)
+}
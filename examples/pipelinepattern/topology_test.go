@@ -0,0 +1,84 @@
+package pipelinepattern
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// backpressured returns a pipeline whose second stage is stuck, with a full
+// input buffer.
+func backpressured(ctx context.Context) *Pipeline {
+	p := New("counters").
+		Stage("parse", 1, 0, func(ctx context.Context, v interface{}) (interface{}, error) {
+			return v, nil
+		}).
+		Stage("write", 1, 2, func(ctx context.Context, v interface{}) (interface{}, error) {
+			<-ctx.Done()
+			return v, ctx.Err()
+		})
+	valStream := make(chan interface{})
+	p.Run(ctx, valStream)
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case valStream <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func ExampleTopology_DOT() {
+	topology := Topology{
+		Name: "counters",
+		Stages: []StageInfo{
+			{Name: "parse", Workers: 4, Buffer: 16, Depth: 3, Processed: 120},
+			{Name: "write", Workers: 1, Buffer: 8, Depth: 8, Processed: 40, Errors: 1},
+		},
+	}
+	fmt.Print(topology.DOT())
+	// Output:
+	// digraph "counters" {
+	//   rankdir=LR;
+	//   node [shape=box];
+	//   "source" [shape=circle];
+	//   "parse" [label="parse\nworkers=4 processed=120 errors=0"];
+	//   "write" [label="write\nworkers=1 processed=40 errors=1", style=filled, fillcolor="#f4a6a6"];
+	//   "sink" [shape=circle];
+	//   "source" -> "parse" [label="3/16"];
+	//   "parse" -> "write" [label="8/8"];
+	//   "write" -> "sink";
+	// }
+}
+
+func TestTopology_Backpressure(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	p := backpressured(ctx)
+	assert.Eventually(t, func() bool {
+		write := p.Topology().Stages[1]
+		return write.Depth == write.Buffer
+	}, time.Second, time.Millisecond)
+
+	topology := p.Topology()
+	assert.Contains(t, topology.DOT(), `"write" [label="write\nworkers=1 processed=0 errors=0", style=filled`)
+	b, err := topology.JSON()
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"name": "write"`)
+	assert.Contains(t, string(b), `"depth": 2`)
+	assert.Contains(t, string(b), `"buffer": 2`)
+}
+
+func TestTopology_DOTEscapes(t *testing.T) {
+	topology := Topology{Name: `say "hi"`, Stages: []StageInfo{{Name: `C:\tmp "x"`, Workers: 1}}}
+	dot := topology.DOT()
+	assert.Contains(t, dot, `digraph "say \"hi\"" {`)
+	assert.Contains(t, dot, `"C:\\tmp \"x\"" [label="C:\\tmp \"x\"\nworkers=1`)
+	assert.Contains(t, dot, `"source" -> "C:\\tmp \"x\"" [label="0/0"]`)
+}
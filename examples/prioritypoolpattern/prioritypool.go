@@ -0,0 +1,173 @@
+package prioritypoolpattern
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrClosed = errors.New("pool closed")
+
+type Task func(ctx context.Context)
+
+// Pool runs tasks on a fixed number of workers, highest priority first. With
+// WithEDF, tasks of the same priority are ordered by the deadline of their
+// context, earliest first. A task whose context is done by the time a worker
+// picks it up is dropped without running.
+type Pool struct {
+	edf    bool
+	onDrop func(ctx context.Context, err error)
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   taskQueue
+	seq     uint64
+	closed  bool
+	dropped atomic.Int64
+	wg      sync.WaitGroup
+}
+
+type Option func(*Pool)
+
+// WithEDF orders tasks of the same priority by earliest deadline. Tasks
+// without a deadline come last.
+func WithEDF() Option {
+	return func(p *Pool) {
+		p.edf = true
+	}
+}
+
+// WithDropHook is called for every task dropped because its context was done.
+func WithDropHook(fn func(ctx context.Context, err error)) Option {
+	return func(p *Pool) {
+		p.onDrop = fn
+	}
+}
+
+func NewPool(workers int, opts ...Option) *Pool {
+	p := &Pool{}
+	p.cond = sync.NewCond(&p.mu)
+	p.queue.pool = p
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues fn with the given priority, higher runs first.
+func (p *Pool) Submit(ctx context.Context, priority int, fn Task) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	t := &item{ctx: ctx, fn: fn, priority: priority, seq: p.seq}
+	t.deadline, t.hasDeadline = ctx.Deadline()
+	p.seq++
+	heap.Push(&p.queue, t)
+	p.cond.Signal()
+	return nil
+}
+
+// Close stops accepting tasks and waits for the queued ones to run or be
+// dropped.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.cond.Broadcast()
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// Dropped reports the number of tasks dropped because their context was done.
+func (p *Pool) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+// Len reports the number of queued tasks.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.queue.Len()
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for {
+		p.mu.Lock()
+		for p.queue.Len() == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.queue.Len() == 0 {
+			p.mu.Unlock()
+			return
+		}
+		t := heap.Pop(&p.queue).(*item)
+		p.mu.Unlock()
+
+		if err := t.ctx.Err(); err != nil {
+			p.dropped.Add(1)
+			if p.onDrop != nil {
+				p.onDrop(t.ctx, err)
+			}
+			continue
+		}
+		t.fn(t.ctx)
+	}
+}
+
+type item struct {
+	ctx         context.Context
+	fn          Task
+	priority    int
+	deadline    time.Time
+	hasDeadline bool
+	seq         uint64 // keeps FIFO order among equals
+}
+
+type taskQueue struct {
+	pool  *Pool
+	items []*item
+}
+
+func (q *taskQueue) Len() int { return len(q.items) }
+
+func (q *taskQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	if q.pool.edf && (a.hasDeadline || b.hasDeadline) {
+		switch {
+		case !b.hasDeadline:
+			return true
+		case !a.hasDeadline:
+			return false
+		case !a.deadline.Equal(b.deadline):
+			return a.deadline.Before(b.deadline)
+		}
+	}
+	return a.seq < b.seq
+}
+
+func (q *taskQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *taskQueue) Push(x any) { q.items = append(q.items, x.(*item)) }
+
+func (q *taskQueue) Pop() any {
+	n := len(q.items)
+	t := q.items[n-1]
+	q.items[n-1] = nil
+	q.items = q.items[:n-1]
+	return t
+}
@@ -0,0 +1,87 @@
+package prioritypoolpattern
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// blockPool occupies the only worker of pool until the returned func is
+// called, so that the tasks submitted meanwhile queue up.
+func blockPool(pool *Pool) (release func()) {
+	started, done := make(chan struct{}), make(chan struct{})
+	pool.Submit(context.TODO(), 0, func(context.Context) {
+		close(started)
+		<-done
+	})
+	<-started
+	return func() { close(done) }
+}
+
+func TestPool_Priority(t *testing.T) {
+	pool := NewPool(1)
+	release := blockPool(pool)
+
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) Task {
+		return func(context.Context) {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+		}
+	}
+	pool.Submit(context.TODO(), 0, record("batch 1"))
+	pool.Submit(context.TODO(), 10, record("db 1"))
+	pool.Submit(context.TODO(), 0, record("batch 2"))
+	pool.Submit(context.TODO(), 10, record("db 2"))
+	release()
+	pool.Close()
+
+	assert.Equal(t, []string{"db 1", "db 2", "batch 1", "batch 2"}, order)
+	assert.ErrorIs(t, pool.Submit(context.TODO(), 0, record("late")), ErrClosed)
+}
+
+func TestPool_EDF(t *testing.T) {
+	pool := NewPool(1, WithEDF())
+	release := blockPool(pool)
+
+	var order []string
+	record := func(name string) Task {
+		return func(context.Context) { order = append(order, name) }
+	}
+	later, cancel1 := context.WithTimeout(context.TODO(), time.Hour)
+	defer cancel1()
+	sooner, cancel2 := context.WithTimeout(context.TODO(), time.Minute)
+	defer cancel2()
+	pool.Submit(context.TODO(), 0, record("no deadline"))
+	pool.Submit(later, 0, record("later"))
+	pool.Submit(sooner, 0, record("sooner"))
+	release()
+	pool.Close()
+
+	assert.Equal(t, []string{"sooner", "later", "no deadline"}, order)
+}
+
+func TestPool_DropsExpiredTasks(t *testing.T) {
+	var dropped []error
+	pool := NewPool(1, WithDropHook(func(ctx context.Context, err error) {
+		dropped = append(dropped, err)
+	}))
+	release := blockPool(pool)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	assert.NoError(t, pool.Submit(ctx, 0, func(context.Context) { ran = true }))
+	<-ctx.Done()
+	release()
+	pool.Close()
+
+	assert.False(t, ran)
+	assert.Equal(t, int64(1), pool.Dropped())
+	assert.Equal(t, []error{context.DeadlineExceeded}, dropped)
+}
@@ -2,10 +2,37 @@ package producerconsumerpattern
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"io"
+	"log/slog"
 	"math/rand"
+	"rhzx3519/go-concurrency/examples/shutdownpattern"
+	"rhzx3519/go-concurrency/examples/watchdogpattern"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var ErrStopped = errors.New("producer stopped")
+
+type Option func(*Producer)
+
+// WithLogger logs every request served by the loop at debug level, with its
+// op and latency. By default nothing is logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Producer) {
+		p.logger = logger
+	}
+}
+
+// WithWatchdog reports loop iterations that get stuck, such as a reply to a
+// caller that has gone away.
+func WithWatchdog(w *watchdogpattern.Watchdog) Option {
+	return func(p *Producer) {
+		p.watchdog = w
+	}
+}
+
 type Parameter struct {
 	outStream chan int
 }
@@ -15,38 +42,104 @@ type Producer struct {
 	anotherStream chan struct{}
 	countStream   chan int
 	count         int
+	cancel        context.CancelFunc
+	done          chan struct{} // closed when the loop exits
+	watchdog      *watchdogpattern.Watchdog
+	logger        *slog.Logger
 }
 
-func NewProducer() *Producer {
-	return &Producer{
+func NewProducer(opts ...Option) *Producer {
+	p := &Producer{
 		paramStream:   make(chan *Parameter),
 		anotherStream: make(chan struct{}),
 		countStream:   make(chan int),
+		done:          make(chan struct{}),
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
+// Run starts the producer loop. The input streams are never closed, senders
+// may still be using them; they should give up on Done instead.
 func (p *Producer) Run(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.watchdog.Run(ctx)
 	go func() {
-		defer close(p.paramStream)
-		defer close(p.anotherStream)
+		defer close(p.done)
+		p.watchdog.Label(ctx)
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-p.anotherStream:
-				fmt.Println("another stream...")
+				p.logger.DebugContext(ctx, "request", slog.String("op", "another"))
 			case param := <-p.paramStream:
-				fmt.Println("param stream...")
-				param.outStream <- p.doProduce()
+				p.watchdog.Begin()
+				start := time.Now()
+				select {
+				case param.outStream <- p.doProduce():
+				case <-ctx.Done():
+					return
+				}
+				p.logger.DebugContext(ctx, "request", slog.String("op", "produce"),
+					slog.Int("count", p.count), slog.Duration("latency", time.Since(start)))
 			case p.countStream <- p.count:
 			}
+			p.watchdog.End()
 		}
 	}()
 }
 
+// Produce asks the loop for a value and waits for it.
+func (p *Producer) Produce(ctx context.Context) (int, error) {
+	// Buffered, so that the loop does not block if ctx is done first.
+	param := &Parameter{outStream: make(chan int, 1)}
+	select {
+	case p.paramStream <- param:
+	case <-p.done:
+		return 0, ErrStopped
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	select {
+	case v := <-param.outStream:
+		return v, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// Count returns the number of values produced so far.
+func (p *Producer) Count(ctx context.Context) (int, error) {
+	select {
+	case count := <-p.countStream:
+		return count, nil
+	case <-p.done:
+		return 0, ErrStopped
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (p *Producer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Shutdown stops the loop and waits for it to exit.
+func (p *Producer) Shutdown(ctx context.Context) error {
+	return shutdown(ctx, p.cancel, p.done)
+}
+
+// Component describes the producer to a shutdownpattern.Coordinator.
+func (p *Producer) Component(name string, dependsOn ...string) shutdownpattern.Component {
+	return shutdownpattern.Component{Name: name, DependsOn: dependsOn, Shutdown: p.Shutdown}
+}
+
 func (p *Producer) doProduce() int {
 	p.count++
-	fmt.Println("doProduce...", p.count)
 	return rand.Int()
 }
 
@@ -55,38 +148,70 @@ func (p *Producer) doProduce() int {
 type RWProducer struct {
 	writeStream chan struct{}
 	readStream  chan int
-	count       int
+	count       atomic.Int64 // written and read by different loops
+	cancel      context.CancelFunc
+	done        chan struct{} // closed when both loops exit
+	logger      *slog.Logger
 }
 
-func NewRWProducer() *RWProducer {
-	return &RWProducer{
+// RWOption configures an RWProducer. It takes no watchdog: its loops only
+// ever block in their select.
+type RWOption func(*RWProducer)
+
+// WithRWLogger logs every request served by the loops at debug level, with
+// its op. By default nothing is logged.
+func WithRWLogger(logger *slog.Logger) RWOption {
+	return func(p *RWProducer) {
+		p.logger = logger
+	}
+}
+
+func NewRWProducer(opts ...RWOption) *RWProducer {
+	p := &RWProducer{
 		writeStream: make(chan struct{}),
 		readStream:  make(chan int),
+		done:        make(chan struct{}),
+		logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
+// Run starts the reader and writer loops. writeStream is never closed,
+// writers may still be using it; they should give up on Done instead.
 func (p *RWProducer) Run(ctx context.Context) <-chan int {
+	ctx, p.cancel = context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(2)
 	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+
+	go func() {
+		defer wg.Done()
 		defer close(p.readStream)
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case p.readStream <- p.get():
-				//fmt.Println("read stream...")
+				p.logger.DebugContext(ctx, "request", slog.String("op", "read"))
 			}
 		}
 	}()
 
 	go func() {
-		defer close(p.writeStream)
+		defer wg.Done()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-p.writeStream:
 				p.plus1()
-				//fmt.Println("write stream...")
+				p.logger.DebugContext(ctx, "request", slog.String("op", "write"))
 			}
 		}
 	}()
@@ -94,12 +219,50 @@ func (p *RWProducer) Run(ctx context.Context) <-chan int {
 	return p.readStream
 }
 
+// Write increments the count.
+func (p *RWProducer) Write(ctx context.Context) error {
+	select {
+	case p.writeStream <- struct{}{}:
+		return nil
+	case <-p.done:
+		return ErrStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *RWProducer) Done() <-chan struct{} {
+	return p.done
+}
+
+// Shutdown stops both loops and waits for them to exit.
+func (p *RWProducer) Shutdown(ctx context.Context) error {
+	return shutdown(ctx, p.cancel, p.done)
+}
+
+// Component describes the producer to a shutdownpattern.Coordinator.
+func (p *RWProducer) Component(name string, dependsOn ...string) shutdownpattern.Component {
+	return shutdownpattern.Component{Name: name, DependsOn: dependsOn, Shutdown: p.Shutdown}
+}
+
 func (p *RWProducer) get() int {
-	//fmt.Println("get...")
-	return p.count
+	return int(p.count.Load())
 }
 
 func (p *RWProducer) plus1() int {
-	p.count++
-	return p.count
+	return int(p.count.Add(1))
+}
+
+func shutdown(ctx context.Context, cancel context.CancelFunc, done <-chan struct{}) error {
+	if cancel == nil {
+		// Never ran.
+		return nil
+	}
+	cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
@@ -1,12 +1,19 @@
 package producerconsumerpattern
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"log/slog"
 	"math/rand/v2"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"rhzx3519/go-concurrency/examples/shutdownpattern"
+	"rhzx3519/go-concurrency/examples/watchdogpattern"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestProducer_Run(t *testing.T) {
@@ -62,7 +69,109 @@ func TestRWProducer_Run(t *testing.T) {
 }
 
 func TestChan(t *testing.T) {
-	stream := make(chan int)
+	// Unbuffered, the send would block forever: nobody is receiving yet.
+	stream := make(chan int, 1)
 	stream <- 1
 	fmt.Println(<-stream)
 }
+
+func TestProducer_Shutdown(t *testing.T) {
+	producer := NewProducer()
+	producer.Run(context.TODO())
+	assert.NoError(t, producer.Shutdown(context.TODO()))
+
+	// Senders racing with the shutdown give up instead of panicking on a
+	// closed channel.
+	select {
+	case producer.anotherStream <- struct{}{}:
+		t.Fatal("producer is still running")
+	case <-producer.Done():
+	}
+}
+
+func TestRWProducer_Shutdown(t *testing.T) {
+	producer := NewRWProducer()
+	readStream := producer.Run(context.TODO())
+	assert.NoError(t, producer.Shutdown(context.TODO()))
+
+	select {
+	case producer.writeStream <- struct{}{}:
+		t.Fatal("producer is still running")
+	case <-producer.Done():
+	}
+	for range readStream {
+	}
+}
+
+func TestProducer_Watchdog(t *testing.T) {
+	stalls := make(chan watchdogpattern.Stall, 1)
+	w := watchdogpattern.NewWatchdog(clockpattern.Real(), "producer", 20*time.Millisecond, func(stall watchdogpattern.Stall) {
+		stalls <- stall
+	})
+	producer := NewProducer(WithWatchdog(w))
+	producer.Run(context.TODO())
+
+	// The caller goes away before reading its result.
+	producer.paramStream <- &Parameter{outStream: make(chan int)}
+	select {
+	case stall := <-stalls:
+		assert.Equal(t, "producer", stall.Name)
+		assert.True(t, strings.Contains(string(stall.Stacks), "Producer).Run"))
+	case <-time.After(time.Second):
+		t.Fatal("stall not reported")
+	}
+	assert.NoError(t, producer.Shutdown(context.TODO()))
+}
+
+func TestProducer_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	producer := NewProducer(WithLogger(logger))
+	producer.Run(context.TODO())
+
+	outStream := make(chan int)
+	producer.paramStream <- &Parameter{outStream: outStream}
+	<-outStream
+	producer.anotherStream <- struct{}{}
+	assert.NoError(t, producer.Shutdown(context.TODO()))
+
+	assert.Contains(t, buf.String(), "msg=request op=produce count=1 latency=")
+	assert.Contains(t, buf.String(), "msg=request op=another")
+}
+
+func TestProducer_Produce(t *testing.T) {
+	producer := NewProducer()
+	producer.Run(context.TODO())
+	for i := 0; i < 3; i++ {
+		_, err := producer.Produce(context.TODO())
+		assert.NoError(t, err)
+	}
+	count, err := producer.Count(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	assert.NoError(t, producer.Shutdown(context.TODO()))
+	_, err = producer.Produce(context.TODO())
+	assert.ErrorIs(t, err, ErrStopped)
+}
+
+func TestRWProducer_Write(t *testing.T) {
+	producer := NewRWProducer()
+	readStream := producer.Run(context.TODO())
+	assert.NoError(t, producer.Write(context.TODO()))
+	assert.NoError(t, producer.Shutdown(context.TODO()))
+	assert.ErrorIs(t, producer.Write(context.TODO()), ErrStopped)
+	for range readStream {
+	}
+}
+
+func TestProducer_Component(t *testing.T) {
+	producer := NewProducer()
+	producer.Run(context.TODO())
+	coordinator := shutdownpattern.NewCoordinator()
+	assert.NoError(t, coordinator.Register(producer.Component("producer")))
+	_, err := coordinator.Shutdown(context.TODO())
+	assert.NoError(t, err)
+	_, err = producer.Produce(context.TODO())
+	assert.ErrorIs(t, err, ErrStopped)
+}
@@ -0,0 +1,181 @@
+package ringbufferpattern
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+const cacheLine = 64
+
+// Queue is a bounded multi-producer/multi-consumer queue built on a ring of
+// sequenced cells (Vyukov's algorithm). Producers and consumers only contend
+// on one atomic counter each, instead of the single lock behind a channel.
+type Queue[T any] struct {
+	_          [cacheLine]byte
+	enqueuePos atomic.Uint64
+	_          [cacheLine - 8]byte
+	dequeuePos atomic.Uint64
+	_          [cacheLine - 8]byte
+	mask       uint64
+	cells      []cell[T]
+}
+
+type cell[T any] struct {
+	// seq == pos: free for the producer at pos.
+	// seq == pos+1: holds the value for the consumer at pos.
+	seq atomic.Uint64
+	val T
+}
+
+// New returns a queue holding at least capacity items; the capacity is
+// rounded up to a power of two.
+func New[T any](capacity int) *Queue[T] {
+	size := uint64(2)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+	q := &Queue[T]{
+		mask:  size - 1,
+		cells: make([]cell[T], size),
+	}
+	for i := range q.cells {
+		q.cells[i].seq.Store(uint64(i))
+	}
+	return q
+}
+
+// TryEnqueue adds v to the queue, it returns false if the queue is full.
+func (q *Queue[T]) TryEnqueue(v T) bool {
+	pos := q.enqueuePos.Load()
+	for {
+		c := &q.cells[pos&q.mask]
+		seq := c.seq.Load()
+		switch diff := int64(seq) - int64(pos); {
+		case diff == 0:
+			if q.enqueuePos.CompareAndSwap(pos, pos+1) {
+				c.val = v
+				c.seq.Store(pos + 1)
+				return true
+			}
+			pos = q.enqueuePos.Load()
+		case diff < 0:
+			// The consumer one lap behind has not freed the cell yet.
+			return false
+		default:
+			pos = q.enqueuePos.Load()
+		}
+	}
+}
+
+// TryDequeue removes the oldest item, it returns false if the queue is
+// empty.
+func (q *Queue[T]) TryDequeue() (T, bool) {
+	pos := q.dequeuePos.Load()
+	for {
+		c := &q.cells[pos&q.mask]
+		seq := c.seq.Load()
+		switch diff := int64(seq) - int64(pos+1); {
+		case diff == 0:
+			if q.dequeuePos.CompareAndSwap(pos, pos+1) {
+				v := c.val
+				var zero T
+				c.val = zero
+				c.seq.Store(pos + q.mask + 1)
+				return v, true
+			}
+			pos = q.dequeuePos.Load()
+		case diff < 0:
+			var zero T
+			return zero, false
+		default:
+			pos = q.dequeuePos.Load()
+		}
+	}
+}
+
+// Enqueue adds v to the queue, waiting while it is full.
+func (q *Queue[T]) Enqueue(v T) {
+	q.EnqueueContext(context.Background(), v)
+}
+
+// Dequeue removes the oldest item, waiting while the queue is empty.
+func (q *Queue[T]) Dequeue() T {
+	v, _ := q.DequeueContext(context.Background())
+	return v
+}
+
+// EnqueueContext adds v to the queue, waiting while it is full until ctx is
+// done.
+func (q *Queue[T]) EnqueueContext(ctx context.Context, v T) error {
+	var b backoff
+	for !q.TryEnqueue(v) {
+		if err := b.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DequeueContext removes the oldest item, waiting while the queue is empty
+// until ctx is done.
+func (q *Queue[T]) DequeueContext(ctx context.Context) (T, error) {
+	var b backoff
+	for {
+		if v, ok := q.TryDequeue(); ok {
+			return v, nil
+		}
+		if err := b.wait(ctx); err != nil {
+			var zero T
+			return zero, err
+		}
+	}
+}
+
+// Len is a snapshot of the number of items, it may be stale by the time it
+// returns.
+func (q *Queue[T]) Len() int {
+	for {
+		deq := q.dequeuePos.Load()
+		enq := q.enqueuePos.Load()
+		if q.dequeuePos.Load() == deq {
+			return int(min(enq-deq, q.mask+1))
+		}
+	}
+}
+
+func (q *Queue[T]) Cap() int {
+	return int(q.mask + 1)
+}
+
+// backoff spins, then yields, then sleeps for longer and longer while a
+// blocking call waits for room or items.
+type backoff struct {
+	n int
+}
+
+const maxSleep = time.Millisecond
+
+func (b *backoff) wait(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.n++
+	switch {
+	case b.n < 16:
+		// Spin.
+	case b.n < 32:
+		runtime.Gosched()
+	default:
+		d := min(time.Duration(b.n-32)*time.Microsecond, maxSleep)
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package ringbufferpattern
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue_TryEnqueueDequeue(t *testing.T) {
+	q := New[int](3)
+	assert.Equal(t, 4, q.Cap())
+	for i := 0; i < 4; i++ {
+		assert.True(t, q.TryEnqueue(i))
+	}
+	assert.False(t, q.TryEnqueue(4))
+	assert.Equal(t, 4, q.Len())
+
+	for i := 0; i < 4; i++ {
+		v, ok := q.TryDequeue()
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+	_, ok := q.TryDequeue()
+	assert.False(t, ok)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestQueue_Context(t *testing.T) {
+	q := New[int](2)
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	_, err := q.DequeueContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	q.Enqueue(1)
+	q.Enqueue(2)
+	assert.ErrorIs(t, q.EnqueueContext(ctx, 3), context.DeadlineExceeded)
+	assert.Equal(t, 1, q.Dequeue())
+}
+
+func TestQueue_MPMC(t *testing.T) {
+	q := New[int](64)
+	const producers, consumers, N = 8, 8, 10000
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < N; i++ {
+				q.Enqueue(p*N + i)
+			}
+		}(p)
+	}
+
+	var mu sync.Mutex
+	seen := make(map[int]bool, producers*N)
+	var consumerWg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		consumerWg.Add(1)
+		go func() {
+			defer consumerWg.Done()
+			for i := 0; i < producers*N/consumers; i++ {
+				v := q.Dequeue()
+				mu.Lock()
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	consumerWg.Wait()
+
+	// Every item is delivered exactly once.
+	assert.Len(t, seen, producers*N)
+	assert.Equal(t, 0, q.Len())
+}
+
+func BenchmarkQueue(b *testing.B) {
+	const capacity = 1024
+	b.Run("ring buffer", func(b *testing.B) {
+		q := New[int](capacity)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				q.Enqueue(1)
+				q.Dequeue()
+			}
+		})
+	})
+
+	b.Run("buffered channel", func(b *testing.B) {
+		c := make(chan int, capacity)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c <- 1
+				<-c
+			}
+		})
+	})
+}
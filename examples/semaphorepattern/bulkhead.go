@@ -0,0 +1,105 @@
+package semaphorepattern
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrBulkheadFull = errors.New("bulkhead: partition is full")
+
+// Bulkhead caps the in-flight work per named partition (a counter name, a
+// tenant, ...), so that one hot partition cannot take all the concurrency of
+// the resource behind it.
+type Bulkhead struct {
+	limit      int64
+	mu         sync.Mutex
+	partitions map[string]*partition
+}
+
+type partition struct {
+	sem  *Weighted
+	refs int // callers holding or waiting on sem
+}
+
+func NewBulkhead(limit int64) *Bulkhead {
+	return &Bulkhead{
+		limit:      limit,
+		partitions: make(map[string]*partition),
+	}
+}
+
+// Acquire takes one slot in the named partition, blocking until a slot is
+// free or ctx is done. The returned release func must be called exactly once.
+func (b *Bulkhead) Acquire(ctx context.Context, name string) (release func(), err error) {
+	p := b.ref(name)
+	if err := p.sem.Acquire(ctx, 1); err != nil {
+		b.unref(name, p)
+		return nil, err
+	}
+	return b.releaser(name, p), nil
+}
+
+// TryAcquire is like Acquire but returns ErrBulkheadFull instead of waiting.
+func (b *Bulkhead) TryAcquire(name string) (release func(), err error) {
+	p := b.ref(name)
+	if !p.sem.TryAcquire(1) {
+		b.unref(name, p)
+		return nil, ErrBulkheadFull
+	}
+	return b.releaser(name, p), nil
+}
+
+// Do runs fn while holding a slot in the named partition.
+func (b *Bulkhead) Do(ctx context.Context, name string, fn func() error) error {
+	release, err := b.Acquire(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// InFlight reports how many slots of the named partition are in use.
+func (b *Bulkhead) InFlight(name string) int64 {
+	b.mu.Lock()
+	p, ok := b.partitions[name]
+	b.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	return p.sem.InUse()
+}
+
+func (b *Bulkhead) releaser(name string, p *partition) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.sem.Release(1)
+			b.unref(name, p)
+		})
+	}
+}
+
+func (b *Bulkhead) ref(name string) *partition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.partitions[name]
+	if !ok {
+		p = &partition{sem: NewWeighted(b.limit)}
+		b.partitions[name] = p
+	}
+	p.refs++
+	return p
+}
+
+// unref drops idle partitions so that the map does not grow with every name
+// ever seen.
+func (b *Bulkhead) unref(name string, p *partition) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p.refs--
+	if p.refs == 0 {
+		delete(b.partitions, name)
+	}
+}
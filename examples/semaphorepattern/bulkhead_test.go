@@ -0,0 +1,63 @@
+package semaphorepattern
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead_IsolatesPartitions(t *testing.T) {
+	bulkhead := NewBulkhead(2)
+
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, err := bulkhead.TryAcquire("hot")
+		assert.NoError(t, err)
+		releases = append(releases, release)
+	}
+	_, err := bulkhead.TryAcquire("hot")
+	assert.ErrorIs(t, err, ErrBulkheadFull)
+	assert.Equal(t, int64(2), bulkhead.InFlight("hot"))
+
+	// A full partition does not affect the others.
+	release, err := bulkhead.TryAcquire("cold")
+	assert.NoError(t, err)
+	release()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bulkhead.Do(ctx, "hot", func() error { return nil }), context.DeadlineExceeded)
+
+	for _, release := range releases {
+		release()
+		release() // releasing twice is a no-op
+	}
+	assert.Equal(t, int64(0), bulkhead.InFlight("hot"))
+	assert.Empty(t, bulkhead.partitions)
+}
+
+func TestBulkhead_Do(t *testing.T) {
+	bulkhead := NewBulkhead(1)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	count := 0
+	const N = 100
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bulkhead.Do(context.TODO(), "reading", func() error {
+				// Only one goroutine at a time, so TryLock always succeeds.
+				assert.True(t, mu.TryLock())
+				count++
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, N, count)
+}
@@ -0,0 +1,126 @@
+package semaphorepattern
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrTooLarge = errors.New("semaphore: weight larger than the semaphore")
+
+// Weighted is a context-aware weighted semaphore. Waiters are served in FIFO
+// order, so a large request is not starved by a stream of small ones.
+type Weighted struct {
+	size    int64
+	cur     int64
+	mu      sync.Mutex
+	waiters list.List
+}
+
+type waiter struct {
+	n     int64
+	ready chan struct{} // closed when the semaphore is acquired
+}
+
+func NewWeighted(n int64) *Weighted {
+	return &Weighted{size: n}
+}
+
+// Acquire acquires the semaphore with a weight of n, blocking until resources
+// are available or ctx is done. On failure it returns ctx.Err(), or ErrTooLarge
+// if n is larger than the semaphore, and leaves the semaphore unchanged.
+func (s *Weighted) Acquire(ctx context.Context, n int64) error {
+	done := ctx.Done()
+
+	s.mu.Lock()
+	select {
+	case <-done:
+		s.mu.Unlock()
+		return ctx.Err()
+	default:
+	}
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+	if n > s.size {
+		// Never going to succeed, don't wait for it.
+		s.mu.Unlock()
+		return ErrTooLarge
+	}
+
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(waiter{n: n, ready: ready})
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// Acquired after ctx was canceled, pretend we did not notice.
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			isFront := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			// If we were at the front and there are extra tokens, wake the
+			// next waiters.
+			if isFront && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	case <-ready:
+		return nil
+	}
+}
+
+// TryAcquire acquires the semaphore with a weight of n without blocking.
+func (s *Weighted) TryAcquire(n int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release releases the semaphore with a weight of n.
+func (s *Weighted) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// InUse reports the weight currently held.
+func (s *Weighted) InUse() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur
+}
+
+func (s *Weighted) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(waiter)
+		if s.size-s.cur < w.n {
+			// Keep FIFO order: do not let smaller waiters jump the queue.
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
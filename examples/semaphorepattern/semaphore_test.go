@@ -0,0 +1,84 @@
+package semaphorepattern
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeighted_Acquire(t *testing.T) {
+	sem := NewWeighted(3)
+	var inFlight, peak atomic.Int64
+
+	var wg sync.WaitGroup
+	const N = 100
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sem.Acquire(context.TODO(), 1); err != nil {
+				t.Error(err)
+				return
+			}
+			defer sem.Release(1)
+			cur := inFlight.Add(1)
+			for {
+				p := peak.Load()
+				if cur <= p || peak.CompareAndSwap(p, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, peak.Load(), int64(3))
+	assert.Equal(t, int64(0), sem.InUse())
+}
+
+func TestWeighted_AcquireCanceled(t *testing.T) {
+	sem := NewWeighted(2)
+	assert.True(t, sem.TryAcquire(2))
+	assert.False(t, sem.TryAcquire(1))
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, sem.Acquire(ctx, 1), context.DeadlineExceeded)
+
+	sem.Release(2)
+	assert.True(t, sem.TryAcquire(2))
+}
+
+func TestWeighted_FIFO(t *testing.T) {
+	sem := NewWeighted(2)
+	assert.NoError(t, sem.Acquire(context.TODO(), 1))
+
+	acquired := make(chan int64, 2)
+	go func() {
+		sem.Acquire(context.TODO(), 2)
+		acquired <- 2
+	}()
+	time.Sleep(10 * time.Millisecond)
+	// The free slot must not be handed to a later, smaller waiter.
+	assert.False(t, sem.TryAcquire(1))
+
+	sem.Release(1)
+	assert.Equal(t, int64(2), <-acquired)
+	sem.Release(2)
+}
+
+func TestWeighted_AcquireTooLarge(t *testing.T) {
+	sem := NewWeighted(2)
+	assert.ErrorIs(t, sem.Acquire(context.Background(), 3), ErrTooLarge)
+	assert.Equal(t, int64(0), sem.InUse())
+
+	// An empty bulkhead sheds everything instead of blocking.
+	_, err := NewBulkhead(0).Acquire(context.Background(), "reading")
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
@@ -0,0 +1,199 @@
+package shardedmappattern
+
+import (
+	"hash/maphash"
+	"math"
+	"reflect"
+	"sync"
+)
+
+const DefaultShards = 32
+
+// Map is a concurrent map split into shards, each guarded by its own lock,
+// so that operations on keys of different shards do not contend.
+type Map[K comparable, V any] struct {
+	seed   maphash.Seed
+	hasher Hasher[K]
+	shards []*shard[K, V]
+}
+
+// Hasher hashes key with seed. Keys that are == must hash the same.
+type Hasher[K comparable] func(seed maphash.Seed, key K) uint64
+
+// Key is the set of key types New knows how to hash.
+type Key interface {
+	~string | ~bool |
+		~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+type shard[K comparable, V any] struct {
+	mu sync.RWMutex
+	m  map[K]V
+}
+
+// New returns a map with the given number of shards, DefaultShards if n is
+// not positive. Other keys than those of Key need NewWithHasher.
+func New[K Key, V any](n int) *Map[K, V] {
+	return NewWithHasher[K, V](n, hashKey[K])
+}
+
+// NewWithHasher is New for any comparable key, hashed by hasher.
+func NewWithHasher[K comparable, V any](n int, hasher Hasher[K]) *Map[K, V] {
+	if hasher == nil {
+		panic("shardedmappattern: nil hasher")
+	}
+	if n <= 0 {
+		n = DefaultShards
+	}
+	m := &Map[K, V]{
+		seed:   maphash.MakeSeed(),
+		hasher: hasher,
+		shards: make([]*shard[K, V], n),
+	}
+	for i := range m.shards {
+		m.shards[i] = &shard[K, V]{m: make(map[K]V)}
+	}
+	return m
+}
+
+func (m *Map[K, V]) Load(key K) (V, bool) {
+	s := m.shard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	return v, ok
+}
+
+func (m *Map[K, V]) Store(key K, value V) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = value
+}
+
+// LoadOrStore returns the existing value for key if present. Otherwise it
+// stores and returns value. loaded reports whether the value was present.
+func (m *Map[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.m[key]; ok {
+		return v, true
+	}
+	s.m[key] = value
+	return value, false
+}
+
+func (m *Map[K, V]) Delete(key K) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, key)
+}
+
+// Compute atomically replaces the value of key with the result of fn, which
+// gets the current value and whether it exists. If fn returns keep == false
+// the key is deleted. fn runs with the shard locked, so it must not call back
+// into the map.
+func (m *Map[K, V]) Compute(key K, fn func(old V, loaded bool) (value V, keep bool)) (V, bool) {
+	s := m.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, loaded := s.m[key]
+	value, keep := fn(old, loaded)
+	if !keep {
+		delete(s.m, key)
+		var zero V
+		return zero, false
+	}
+	s.m[key] = value
+	return value, true
+}
+
+// Range calls fn for each key and value until fn returns false. Each shard is
+// copied before fn is called, so fn may modify the map: a key stored or
+// deleted during Range may or may not be visited.
+func (m *Map[K, V]) Range(fn func(key K, value V) bool) {
+	type entry struct {
+		key   K
+		value V
+	}
+	var entries []entry
+	for _, s := range m.shards {
+		entries = entries[:0]
+		s.mu.RLock()
+		for k, v := range s.m {
+			entries = append(entries, entry{k, v})
+		}
+		s.mu.RUnlock()
+		for _, e := range entries {
+			if !fn(e.key, e.value) {
+				return
+			}
+		}
+	}
+}
+
+func (m *Map[K, V]) Len() int {
+	n := 0
+	for _, s := range m.shards {
+		s.mu.RLock()
+		n += len(s.m)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func (m *Map[K, V]) shard(key K) *shard[K, V] {
+	return m.shards[m.hash(key)%uint64(len(m.shards))]
+}
+
+func (m *Map[K, V]) hash(key K) uint64 {
+	return m.hasher(m.seed, key)
+}
+
+func hashKey[K Key](seed maphash.Seed, key K) uint64 {
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(seed, k)
+	case int:
+		return mix(seed, uint64(k))
+	case int64:
+		return mix(seed, uint64(k))
+	case uint64:
+		return mix(seed, k)
+	}
+	// Named and less common basic types.
+	v := reflect.ValueOf(key)
+	switch v.Kind() {
+	case reflect.String:
+		return maphash.String(seed, v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return mix(seed, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return mix(seed, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f == 0 {
+			// -0 == +0, they must land in the same shard.
+			f = 0
+		}
+		return mix(seed, math.Float64bits(f))
+	case reflect.Bool:
+		if v.Bool() {
+			return mix(seed, 1)
+		}
+		return mix(seed, 0)
+	}
+	panic("shardedmappattern: unreachable")
+}
+
+func mix(seed maphash.Seed, v uint64) uint64 {
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(v >> (8 * i))
+	}
+	return maphash.Bytes(seed, b[:])
+}
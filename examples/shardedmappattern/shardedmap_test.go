@@ -0,0 +1,126 @@
+package shardedmappattern
+
+import (
+	"hash/maphash"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap_Compute(t *testing.T) {
+	m := New[string, int](4)
+	var wg sync.WaitGroup
+	const N, names = 1000, 10
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m.Compute("counter"+strconv.Itoa(i%names), func(old int, _ bool) (int, bool) {
+				return old + 1, true
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, names, m.Len())
+	m.Range(func(key string, value int) bool {
+		assert.Equal(t, N/names, value, key)
+		return true
+	})
+
+	// Returning keep == false deletes the key.
+	_, ok := m.Compute("counter0", func(int, bool) (int, bool) { return 0, false })
+	assert.False(t, ok)
+	_, ok = m.Load("counter0")
+	assert.False(t, ok)
+}
+
+func TestMap_LoadOrStore(t *testing.T) {
+	m := New[int, string](0)
+	v, loaded := m.LoadOrStore(1, "a")
+	assert.False(t, loaded)
+	assert.Equal(t, "a", v)
+	v, loaded = m.LoadOrStore(1, "b")
+	assert.True(t, loaded)
+	assert.Equal(t, "a", v)
+
+	m.Store(1, "c")
+	v, _ = m.Load(1)
+	assert.Equal(t, "c", v)
+	m.Delete(1)
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestMap_Hash(t *testing.T) {
+	floats := New[float64, int](DefaultShards)
+	zero, negZero := 0.0, math.Copysign(0, -1)
+	assert.Equal(t, floats.hash(zero), floats.hash(negZero))
+	floats.Store(zero, 1)
+	v, ok := floats.Load(negZero)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	type name string
+	names := New[name, int](DefaultShards)
+	assert.Equal(t, names.hash("a"), maphash.String(names.seed, "a"))
+
+	assert.Panics(t, func() { NewWithHasher[struct{ a int }, int](0, nil) })
+}
+
+func TestMap_RangeWhileMutating(t *testing.T) {
+	type key struct{ a, b int }
+	m := NewWithHasher[key, int](8, func(seed maphash.Seed, k key) uint64 {
+		return maphash.String(seed, strconv.Itoa(k.a)+","+strconv.Itoa(k.b))
+	})
+	for i := 0; i < 100; i++ {
+		m.Store(key{i, i}, i)
+	}
+	visited := 0
+	m.Range(func(k key, v int) bool {
+		// Mutating the map from fn must not deadlock.
+		m.Delete(k)
+		m.Store(key{k.a, -1}, v)
+		visited++
+		return true
+	})
+	assert.GreaterOrEqual(t, visited, 100)
+
+	stopped := 0
+	m.Range(func(key, int) bool {
+		stopped++
+		return false
+	})
+	assert.Equal(t, 1, stopped)
+}
+
+func BenchmarkMap_Compute(b *testing.B) {
+	b.Run("sharded map", func(b *testing.B) {
+		m := New[string, int](0)
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				m.Compute(strconv.Itoa(i%1024), func(old int, _ bool) (int, bool) {
+					return old + 1, true
+				})
+				i++
+			}
+		})
+	})
+
+	b.Run("single mutex", func(b *testing.B) {
+		var mu sync.Mutex
+		m := make(map[string]int)
+		b.RunParallel(func(pb *testing.PB) {
+			i := 0
+			for pb.Next() {
+				mu.Lock()
+				m[strconv.Itoa(i%1024)]++
+				mu.Unlock()
+				i++
+			}
+		})
+	})
+}
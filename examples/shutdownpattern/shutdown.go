@@ -0,0 +1,171 @@
+package shutdownpattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Component is something that has to be stopped gracefully. A component is
+// shut down before every component it depends on.
+type Component struct {
+	Name      string
+	DependsOn []string
+	// Drain is the deadline given to Shutdown. Zero means no deadline other
+	// than the one of the coordinator's context.
+	Drain    time.Duration
+	Shutdown func(ctx context.Context) error
+	// InFlight optionally reports the work still in progress; it is sampled
+	// when Shutdown returns.
+	InFlight func() int
+}
+
+// ComponentReport tells how the shutdown of one component went.
+type ComponentReport struct {
+	Name     string
+	Elapsed  time.Duration
+	InFlight int
+	Err      error
+}
+
+type Report []ComponentReport
+
+// Err joins the errors of all the components.
+func (r Report) Err() error {
+	var errs []error
+	for _, c := range r {
+		if c.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, c.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	for _, c := range r {
+		status := "ok"
+		if c.Err != nil {
+			status = c.Err.Error()
+		}
+		fmt.Fprintf(&b, "%s: %s (in flight: %d)\n", c.Name, status, c.InFlight)
+	}
+	return b.String()
+}
+
+type Coordinator struct {
+	mu         sync.Mutex
+	components map[string]Component
+	order      []string // registration order, keeps the shutdown order stable
+}
+
+func NewCoordinator() *Coordinator {
+	return &Coordinator{
+		components: make(map[string]Component),
+	}
+}
+
+func (c *Coordinator) Register(comp Component) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.components[comp.Name]; ok {
+		return fmt.Errorf("register %v: already registered", comp.Name)
+	}
+	c.components[comp.Name] = comp
+	c.order = append(c.order, comp.Name)
+	return nil
+}
+
+// Shutdown stops the components one by one, dependents first. A component
+// that fails or misses its drain deadline does not stop the others from being
+// shut down; everything is collected in the report.
+func (c *Coordinator) Shutdown(ctx context.Context) (Report, error) {
+	order, err := c.shutdownOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	report := make(Report, 0, len(order))
+	for _, comp := range order {
+		report = append(report, shutdown(ctx, comp))
+	}
+	return report, report.Err()
+}
+
+func shutdown(ctx context.Context, comp Component) ComponentReport {
+	if comp.Drain > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, comp.Drain)
+		defer cancel()
+	}
+
+	start := time.Now()
+	errStream := make(chan error, 1)
+	go func() {
+		errStream <- comp.Shutdown(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errStream:
+	case <-ctx.Done():
+		// Do not let a component that ignores its context hold up the rest.
+		err = ctx.Err()
+	}
+
+	r := ComponentReport{Name: comp.Name, Elapsed: time.Since(start), Err: err}
+	if comp.InFlight != nil {
+		r.InFlight = comp.InFlight()
+	}
+	return r
+}
+
+// shutdownOrder sorts the components so that dependents come before their
+// dependencies.
+func (c *Coordinator) shutdownOrder() ([]Component, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(c.components))
+	var startOrder []Component
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		comp, ok := c.components[name]
+		if !ok {
+			return fmt.Errorf("shutdown: %v depends on unknown component %v", path[len(path)-1], name)
+		}
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("shutdown: dependency cycle %v", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range comp.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		startOrder = append(startOrder, comp)
+		return nil
+	}
+	for _, name := range c.order {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// Dependencies are started first, so they are stopped last.
+	slices.Reverse(startOrder)
+	return startOrder, nil
+}
@@ -0,0 +1,83 @@
+package shutdownpattern
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleCoordinator() {
+	coordinator := NewCoordinator()
+	stop := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			fmt.Println("stopping", name)
+			return nil
+		}
+	}
+	coordinator.Register(Component{Name: "db", Shutdown: stop("db")})
+	coordinator.Register(Component{Name: "mysql client", DependsOn: []string{"db"}, Shutdown: stop("mysql client")})
+	coordinator.Register(Component{Name: "producer", DependsOn: []string{"mysql client"}, Shutdown: stop("producer")})
+
+	report, err := coordinator.Shutdown(context.TODO())
+	fmt.Print(report)
+	fmt.Println(err)
+	// Output:
+	// stopping producer
+	// stopping mysql client
+	// stopping db
+	// producer: ok (in flight: 0)
+	// mysql client: ok (in flight: 0)
+	// db: ok (in flight: 0)
+	// <nil>
+}
+
+func TestCoordinator_DrainDeadline(t *testing.T) {
+	coordinator := NewCoordinator()
+	var dbStopped bool
+	assert.NoError(t, coordinator.Register(Component{
+		Name: "db",
+		Shutdown: func(context.Context) error {
+			dbStopped = true
+			return nil
+		},
+	}))
+	assert.NoError(t, coordinator.Register(Component{
+		Name:      "stuck",
+		DependsOn: []string{"db"},
+		Drain:     10 * time.Millisecond,
+		Shutdown: func(context.Context) error {
+			// Ignores its context on purpose.
+			time.Sleep(time.Second)
+			return nil
+		},
+		InFlight: func() int { return 3 },
+	}))
+	assert.Error(t, coordinator.Register(Component{Name: "db"}))
+
+	start := time.Now()
+	report, err := coordinator.Shutdown(context.TODO())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, dbStopped)
+	assert.Equal(t, "stuck", report[0].Name)
+	assert.Equal(t, 3, report[0].InFlight)
+	assert.NoError(t, report[1].Err)
+}
+
+func TestCoordinator_InvalidDependencies(t *testing.T) {
+	noop := func(context.Context) error { return nil }
+
+	coordinator := NewCoordinator()
+	coordinator.Register(Component{Name: "a", DependsOn: []string{"b"}, Shutdown: noop})
+	coordinator.Register(Component{Name: "b", DependsOn: []string{"a"}, Shutdown: noop})
+	_, err := coordinator.Shutdown(context.TODO())
+	assert.EqualError(t, err, "shutdown: dependency cycle a -> b -> a")
+
+	coordinator = NewCoordinator()
+	coordinator.Register(Component{Name: "a", DependsOn: []string{"missing"}, Shutdown: noop})
+	_, err = coordinator.Shutdown(context.TODO())
+	assert.EqualError(t, err, "shutdown: a depends on unknown component missing")
+}
@@ -0,0 +1,143 @@
+package singleflightpattern
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var errPanicked = errors.New("singleflight: function panicked")
+
+// Group coalesces concurrent calls with the same key into a single execution
+// of fn. Callers arriving while the call is in flight wait for it and share
+// its result. If shareFor is positive, a successful result keeps being served
+// for that long after the call returns.
+type Group[K comparable, V any] struct {
+	shareFor time.Duration
+	mu       sync.Mutex
+	calls    map[K]*call[V]
+}
+
+type call[V any] struct {
+	done     chan struct{} // closed once val and err are set
+	val      V
+	err      error
+	panicked any
+	dups     int
+	waiters  int // callers still waiting, the call is canceled at zero
+	cancel   context.CancelFunc
+	expires  time.Time // zero while in flight
+}
+
+func NewGroup[K comparable, V any](shareFor time.Duration) *Group[K, V] {
+	return &Group[K, V]{
+		shareFor: shareFor,
+		calls:    make(map[K]*call[V]),
+	}
+}
+
+// Do executes fn for key, making sure only one execution is in flight at a
+// time. shared reports whether the result was given to more than one caller.
+//
+// fn runs on a context of its own, which keeps the values of ctx but is only
+// canceled once every caller waiting for the result has given up. Each
+// caller waits no longer than its own ctx allows. If fn panics, the panic is
+// raised again in the caller that started the call, the others get an error.
+func (g *Group[K, V]) Do(ctx context.Context, key K, fn func(ctx context.Context) (V, error)) (v V, err error, shared bool) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		if c.expires.IsZero() || time.Now().Before(c.expires) {
+			c.dups++
+			c.waiters++
+			g.mu.Unlock()
+			return g.wait(ctx, c, key, false)
+		}
+		delete(g.calls, key)
+	}
+	c := &call[V]{done: make(chan struct{}), waiters: 1}
+	var callCtx context.Context
+	callCtx, c.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	go g.doCall(callCtx, c, key, fn)
+	return g.wait(ctx, c, key, true)
+}
+
+// Forget drops key, so the next Do starts a new call instead of waiting for
+// or reusing the current one.
+func (g *Group[K, V]) Forget(key K) {
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
+
+// wait waits for the result of c, or for ctx. The last waiter to give up
+// cancels the call.
+func (g *Group[K, V]) wait(ctx context.Context, c *call[V], key K, leader bool) (v V, err error, shared bool) {
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		g.mu.Lock()
+		select {
+		case <-c.done:
+			// The result came in meanwhile, take it after all.
+		default:
+			c.waiters--
+			if c.waiters == 0 {
+				c.cancel()
+				// Nobody would get the result, do not let new callers
+				// join a canceled call.
+				if g.calls[key] == c {
+					delete(g.calls, key)
+				}
+			}
+			g.mu.Unlock()
+			return v, ctx.Err(), false
+		}
+		g.mu.Unlock()
+	}
+
+	if leader && c.panicked != nil {
+		panic(c.panicked)
+	}
+	g.mu.Lock()
+	shared = c.dups > 0
+	g.mu.Unlock()
+	return c.val, c.err, shared
+}
+
+func (g *Group[K, V]) doCall(ctx context.Context, c *call[V], key K, fn func(ctx context.Context) (V, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			// Do not leave the waiters hanging, fn panicked.
+			c.panicked = r
+			c.err = errPanicked
+		}
+		c.cancel()
+		g.mu.Lock()
+		if g.calls[key] == c {
+			if c.err == nil && g.shareFor > 0 {
+				c.expires = time.Now().Add(g.shareFor)
+				// Drop the result once it expires even if key is never asked
+				// for again, or the map would keep growing.
+				time.AfterFunc(g.shareFor, func() { g.expire(key, c) })
+			} else {
+				delete(g.calls, key)
+			}
+		}
+		close(c.done)
+		g.mu.Unlock()
+	}()
+
+	c.val, c.err = fn(ctx)
+}
+
+func (g *Group[K, V]) expire(key K, c *call[V]) {
+	g.mu.Lock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+	g.mu.Unlock()
+}
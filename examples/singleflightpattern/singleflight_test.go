@@ -0,0 +1,192 @@
+package singleflightpattern
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Do(t *testing.T) {
+	g := NewGroup[string, int](0)
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	const N = 100
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, _ := g.Do(context.TODO(), "reading", func(context.Context) (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, 42, v)
+		}()
+	}
+	// Wait for every caller to join the in-flight call.
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		c, ok := g.calls["reading"]
+		return ok && c.dups == N-1
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+
+	// Without result sharing the next call runs again.
+	g.Do(context.TODO(), "reading", func(context.Context) (int, error) {
+		calls.Add(1)
+		return 0, nil
+	})
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestGroup_ShareFor(t *testing.T) {
+	g := NewGroup[string, int](50 * time.Millisecond)
+	var calls atomic.Int32
+	fn := func(context.Context) (int, error) {
+		return int(calls.Add(1)), nil
+	}
+
+	v, _, shared := g.Do(context.TODO(), "reading", fn)
+	assert.Equal(t, 1, v)
+	assert.False(t, shared)
+	v, _, shared = g.Do(context.TODO(), "reading", fn)
+	assert.Equal(t, 1, v)
+	assert.True(t, shared)
+
+	time.Sleep(60 * time.Millisecond)
+	v, _, _ = g.Do(context.TODO(), "reading", fn)
+	assert.Equal(t, 2, v)
+
+	g.Forget("reading")
+	v, _, _ = g.Do(context.TODO(), "reading", fn)
+	assert.Equal(t, 3, v)
+}
+
+func TestGroup_ExpiredResultsAreDropped(t *testing.T) {
+	g := NewGroup[int, int](10 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		g.Do(context.TODO(), i, func(context.Context) (int, error) { return i, nil })
+	}
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return len(g.calls) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestGroup_ErrorsAreNotShared(t *testing.T) {
+	g := NewGroup[string, int](time.Minute)
+	boom := errors.New("boom")
+	_, err, _ := g.Do(context.TODO(), "reading", func(context.Context) (int, error) { return 0, boom })
+	assert.ErrorIs(t, err, boom)
+
+	v, err, _ := g.Do(context.TODO(), "reading", func(context.Context) (int, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestGroup_Panic(t *testing.T) {
+	g := NewGroup[string, int](0)
+	assert.Panics(t, func() {
+		g.Do(context.TODO(), "reading", func(context.Context) (int, error) { panic("boom") })
+	})
+	_, err, _ := g.Do(context.TODO(), "reading", func(context.Context) (int, error) { return 0, nil })
+	assert.NoError(t, err)
+}
+
+func TestGroup_CallersWaitOnTheirOwnContext(t *testing.T) {
+	g := NewGroup[string, int](0)
+	release := make(chan struct{})
+	leader := make(chan error)
+	go func() {
+		_, err, _ := g.Do(context.TODO(), "reading", func(context.Context) (int, error) {
+			<-release
+			return 42, nil
+		})
+		leader <- err
+	}()
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		_, ok := g.calls["reading"]
+		return ok
+	}, time.Second, time.Millisecond)
+
+	// A follower with a shorter deadline does not wait for the leader.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err, _ := g.Do(ctx, "reading", func(context.Context) (int, error) { return 0, nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	close(release)
+	assert.NoError(t, <-leader)
+}
+
+func TestGroup_LeaderCancelDoesNotFailFollowers(t *testing.T) {
+	g := NewGroup[string, int](0)
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err, _ := g.Do(ctx, "reading", func(ctx context.Context) (int, error) {
+			select {
+			case <-release:
+				return 42, nil
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			}
+		})
+		leader <- err
+	}()
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		_, ok := g.calls["reading"]
+		return ok
+	}, time.Second, time.Millisecond)
+
+	follower := make(chan int)
+	go func() {
+		v, err, _ := g.Do(context.TODO(), "reading", func(context.Context) (int, error) { return 0, nil })
+		assert.NoError(t, err)
+		follower <- v
+	}()
+	assert.Eventually(t, func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.calls["reading"].dups == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-leader, context.Canceled)
+	close(release)
+	assert.Equal(t, 42, <-follower)
+}
+
+func TestGroup_CanceledOnceEveryCallerGaveUp(t *testing.T) {
+	g := NewGroup[string, int](0)
+	canceled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err, _ := g.Do(ctx, "reading", func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		close(canceled)
+		return 0, ctx.Err()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	<-canceled
+}
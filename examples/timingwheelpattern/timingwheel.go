@@ -0,0 +1,203 @@
+package timingwheelpattern
+
+import (
+	"container/list"
+	"context"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync"
+	"time"
+)
+
+const levels = 4
+
+// Wheel schedules delayed and recurring jobs on a hierarchical timing wheel:
+// one goroutine and one ticker serve any number of timers, instead of one
+// runtime timer per job. Level 0 has one slot per tick; each level above
+// covers a whole turn of the level below in each of its slots, and its
+// timers cascade down as their time gets closer.
+//
+// Jobs fire with a resolution of one tick, and each one runs in its own
+// goroutine.
+type Wheel struct {
+	clock clockpattern.Clock
+	tick  time.Duration
+	size  int64
+
+	mu      sync.Mutex
+	start   time.Time
+	current int64 // ticks processed since start
+	slots   [levels][]*list.List
+}
+
+// Timer is a job scheduled on a Wheel.
+type Timer struct {
+	wheel   *Wheel
+	fn      func()
+	period  int64 // in ticks, zero for one-shot timers
+	expires int64 // in ticks since the wheel started
+	slot    *list.List
+	elem    *list.Element
+}
+
+// NewWheel returns a wheel with slots of one tick and size slots per level.
+func NewWheel(clock clockpattern.Clock, tick time.Duration, size int) *Wheel {
+	w := &Wheel{
+		clock: clock,
+		tick:  tick,
+		size:  int64(size),
+		start: clock.Now(),
+	}
+	for l := range w.slots {
+		w.slots[l] = make([]*list.List, size)
+		for i := range w.slots[l] {
+			w.slots[l][i] = list.New()
+		}
+	}
+	return w
+}
+
+// Run drives the wheel until ctx is done.
+func (w *Wheel) Run(ctx context.Context) {
+	ticker := w.clock.NewTicker(w.tick)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				w.advance()
+			}
+		}
+	}()
+}
+
+// AfterFunc runs fn once after d.
+func (w *Wheel) AfterFunc(d time.Duration, fn func()) *Timer {
+	t := &Timer{wheel: w, fn: fn}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t.expires = w.current + w.ticks(d)
+	w.add(t)
+	return t
+}
+
+// Every runs fn every d, starting after d.
+func (w *Wheel) Every(d time.Duration, fn func()) *Timer {
+	t := &Timer{wheel: w, fn: fn}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t.period = w.ticks(d)
+	t.expires = w.current + t.period
+	w.add(t)
+	return t
+}
+
+// Stop cancels the timer. It reports whether the timer was still pending.
+func (t *Timer) Stop() bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.remove(t)
+}
+
+// Reset reschedules the timer to fire after d, and then every d for a
+// recurring one. It reports whether the timer was still pending.
+func (t *Timer) Reset(d time.Duration) bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := w.remove(t)
+	if t.period > 0 {
+		t.period = w.ticks(d)
+	}
+	t.expires = w.current + w.ticks(d)
+	w.add(t)
+	return pending
+}
+
+// Len reports the number of pending timers.
+func (w *Wheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for l := range w.slots {
+		for _, slot := range w.slots[l] {
+			n += slot.Len()
+		}
+	}
+	return n
+}
+
+// ticks rounds d up to whole ticks, at least one.
+func (w *Wheel) ticks(d time.Duration) int64 {
+	return max(1, int64((d+w.tick-1)/w.tick))
+}
+
+func (w *Wheel) add(t *Timer) {
+	delta := t.expires - w.current
+	level, span := 0, int64(1)
+	for level < levels-1 && delta >= span*w.size {
+		level++
+		span *= w.size
+	}
+	// Timers beyond the top level go round it again when cascaded.
+	t.slot = w.slots[level][(t.expires/span)%w.size]
+	t.elem = t.slot.PushBack(t)
+}
+
+func (w *Wheel) remove(t *Timer) bool {
+	if t.slot == nil {
+		return false
+	}
+	t.slot.Remove(t.elem)
+	t.slot, t.elem = nil, nil
+	return true
+}
+
+// advance processes every tick up to the clock's current time. Reading the
+// clock instead of counting ticks means that a late ticker only delays the
+// jobs, it does not lose time.
+func (w *Wheel) advance() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	target := int64(w.clock.Now().Sub(w.start) / w.tick)
+	for w.current < target {
+		w.current++
+		w.cascade()
+		slot := w.slots[0][w.current%w.size]
+		for slot.Len() > 0 {
+			t := slot.Remove(slot.Front()).(*Timer)
+			t.slot, t.elem = nil, nil
+			go t.fn()
+			if t.period > 0 {
+				t.expires = w.current + t.period
+				w.add(t)
+			}
+		}
+	}
+}
+
+// cascade moves the timers of the upper levels whose slot has come down to
+// the level below, highest level first.
+func (w *Wheel) cascade() {
+	span := int64(1)
+	var due []*list.List
+	for level := 1; level < levels; level++ {
+		span *= w.size
+		if w.current%span != 0 {
+			break
+		}
+		due = append(due, w.slots[level][(w.current/span)%w.size])
+	}
+	for i := len(due) - 1; i >= 0; i-- {
+		slot := due[i]
+		var timers []*Timer
+		for slot.Len() > 0 {
+			timers = append(timers, slot.Remove(slot.Front()).(*Timer))
+		}
+		for _, t := range timers {
+			w.add(t)
+		}
+	}
+}
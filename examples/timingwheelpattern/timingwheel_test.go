@@ -0,0 +1,88 @@
+package timingwheelpattern
+
+import (
+	"context"
+	"math/rand/v2"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWheel_FiresOnTime(t *testing.T) {
+	clock := clockpattern.NewFake(time.Now())
+	// Small slots so that most timers cascade through several levels.
+	wheel := NewWheel(clock, time.Millisecond, 8)
+
+	const N, horizon = 2000, 8 * 8 * 8 * 8 * 2 // beyond the top level too
+	expected := make(map[int64]int)
+	var mu sync.Mutex
+	var now int64
+	var wg sync.WaitGroup
+	for i := 0; i < N; i++ {
+		ticks := 1 + rand.Int64N(horizon)
+		expected[ticks]++
+		wheel.AfterFunc(time.Duration(ticks)*time.Millisecond, func() {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, ticks, now)
+		})
+	}
+	assert.Equal(t, N, wheel.Len())
+
+	for tick := int64(1); tick <= horizon; tick++ {
+		mu.Lock()
+		now = tick
+		mu.Unlock()
+		wg.Add(expected[tick])
+		clock.Advance(time.Millisecond)
+		wheel.advance()
+		wg.Wait()
+	}
+	assert.Equal(t, 0, wheel.Len())
+}
+
+func TestWheel_StopAndReset(t *testing.T) {
+	clock := clockpattern.NewFake(time.Now())
+	wheel := NewWheel(clock, time.Millisecond, 16)
+	fired := make(chan string, 10)
+
+	stopped := wheel.AfterFunc(5*time.Millisecond, func() { fired <- "stopped" })
+	reset := wheel.AfterFunc(5*time.Millisecond, func() { fired <- "reset" })
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+	assert.True(t, reset.Reset(100*time.Millisecond))
+
+	clock.Advance(50 * time.Millisecond)
+	wheel.advance()
+	assert.Empty(t, fired)
+
+	clock.Advance(50 * time.Millisecond)
+	wheel.advance()
+	assert.Equal(t, "reset", <-fired)
+	assert.False(t, reset.Stop())
+}
+
+func TestWheel_Every(t *testing.T) {
+	clock := clockpattern.NewFake(time.Now())
+	wheel := NewWheel(clock, 10*time.Millisecond, 16)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	wheel.Run(ctx)
+
+	fired := make(chan time.Time, 10)
+	timer := wheel.Every(time.Second, func() { fired <- clock.Now() })
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		select {
+		case <-fired:
+		case <-time.After(time.Second):
+			t.Fatal("recurring timer did not fire")
+		}
+	}
+	assert.True(t, timer.Stop())
+	assert.Equal(t, 0, wheel.Len())
+}
@@ -0,0 +1,441 @@
+package walqueuepattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+var ErrClosed = errors.New("queue closed")
+
+const defaultSegmentSize = 4 << 20
+
+// Queue is a durable FIFO queue. Every message and every ack is appended to
+// a log split in segment files, so the queue survives a crash: on Open the log
+// is replayed and the messages that were not acked are delivered again
+// (at-least-once). Segments are deleted once all their messages are acked;
+// messages that stay unacked for long are copied forward, so that they do
+// not keep the segments after theirs around.
+type Queue struct {
+	dir         string
+	segmentSize int64
+	syncWrites  bool
+
+	mu         sync.Mutex
+	closed     bool
+	nextID     uint64
+	ready      []*item // FIFO of messages waiting for a consumer
+	inFlight   map[uint64]*item
+	segments   []*segment // oldest first, the last one is active
+	active     segmentFile
+	activeSize int64
+	torn       bool          // a failed write left bytes past activeSize
+	notify     chan struct{} // closed and replaced when a message is ready
+}
+
+// segmentFile is the part of *os.File the active segment is written with.
+type segmentFile interface {
+	Write(b []byte) (int, error)
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+type item struct {
+	id   uint64
+	data []byte
+	seg  *segment
+}
+
+// Message is a delivered message. It must be acked once processed, or
+// nacked to be delivered again.
+type Message struct {
+	ID   uint64
+	Data []byte
+	q    *Queue
+}
+
+type Option func(*Queue)
+
+// WithSegmentSize sets the size after which a new segment file is started.
+func WithSegmentSize(n int64) Option {
+	return func(q *Queue) {
+		q.segmentSize = n
+	}
+}
+
+// WithSyncWrites fsyncs every write. Without it a crash of the machine, not
+// only of the process, can lose the last writes.
+func WithSyncWrites() Option {
+	return func(q *Queue) {
+		q.syncWrites = true
+	}
+}
+
+// Open opens the queue stored in dir, creating it if needed, and recovers
+// the messages that were not acked.
+func Open(dir string, opts ...Option) (*Queue, error) {
+	q := &Queue{
+		dir:         dir,
+		segmentSize: defaultSegmentSize,
+		inFlight:    make(map[uint64]*item),
+		notify:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := q.recover(); err != nil {
+		return nil, err
+	}
+	if err := q.roll(); err != nil {
+		return nil, err
+	}
+	if err := q.compact(); err != nil {
+		q.active.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) recover() error {
+	segments, err := listSegments(q.dir)
+	if err != nil {
+		return err
+	}
+	items := make(map[uint64]*item)
+	for i, seg := range segments {
+		offset, err := readRecords(seg.path, func(r record) {
+			switch r.typ {
+			case recordEnqueue:
+				if it, ok := items[r.id]; ok {
+					// Copied forward, the crash came before the old
+					// segment was deleted.
+					it.seg.drop(it)
+				}
+				it := &item{id: r.id, data: r.payload}
+				seg.add(it)
+				items[r.id] = it
+			case recordAck:
+				if it, ok := items[r.id]; ok {
+					it.seg.drop(it)
+					delete(items, r.id)
+				}
+			}
+			q.nextID = max(q.nextID, r.id+1)
+		})
+		if errors.Is(err, errCorrupted) && i == len(segments)-1 {
+			// A crash in the middle of a write leaves a torn record at the end
+			// of the last segment. Drop it.
+			err = os.Truncate(seg.path, offset)
+		}
+		if err != nil {
+			return fmt.Errorf("recover %v: %w", seg.path, err)
+		}
+		seg.size = offset
+	}
+
+	for _, it := range items {
+		q.ready = append(q.ready, it)
+	}
+	sort.Slice(q.ready, func(i, j int) bool { return q.ready[i].id < q.ready[j].id })
+	q.segments = segments
+	return nil
+}
+
+// Send appends data to the queue.
+func (q *Queue) Send(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	if q.activeSize >= q.segmentSize {
+		if err := q.roll(); err != nil {
+			return err
+		}
+		if err := q.compact(); err != nil {
+			return err
+		}
+	}
+	id := q.nextID
+	data = append([]byte(nil), data...)
+	if err := q.write(record{typ: recordEnqueue, id: id, payload: data}); err != nil {
+		return err
+	}
+	q.nextID++
+	it := &item{id: id, data: data}
+	q.segments[len(q.segments)-1].add(it)
+	q.ready = append(q.ready, it)
+	close(q.notify)
+	q.notify = make(chan struct{})
+	return nil
+}
+
+// Receive waits for the next message.
+func (q *Queue) Receive(ctx context.Context) (*Message, error) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if len(q.ready) > 0 {
+			it := q.ready[0]
+			q.ready[0] = nil
+			q.ready = q.ready[1:]
+			q.inFlight[it.id] = it
+			q.mu.Unlock()
+			return &Message{ID: it.id, Data: it.data, q: q}, nil
+		}
+		notify := q.notify
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-notify:
+		}
+	}
+}
+
+// Messages delivers the messages on a channel until ctx is done or the queue
+// is closed.
+func (q *Queue) Messages(ctx context.Context) <-chan *Message {
+	msgStream := make(chan *Message)
+	go func() {
+		defer close(msgStream)
+		for {
+			msg, err := q.Receive(ctx)
+			if err != nil {
+				return
+			}
+			select {
+			case msgStream <- msg:
+			case <-ctx.Done():
+				// Not handed over, deliver it again later.
+				msg.Nack()
+				return
+			}
+		}
+	}()
+	return msgStream
+}
+
+// Sender sends the values written on the returned channel to the queue,
+// until the channel is closed or ctx is done. The error stream gets the error
+// that stopped it, if any, and is closed once it stopped; writers should
+// give up on it. A value is on disk once the next one is taken, or once the
+// error stream is closed.
+func (q *Queue) Sender(ctx context.Context) (chan<- []byte, <-chan error) {
+	dataStream := make(chan []byte)
+	errStream := make(chan error, 1)
+	go func() {
+		defer close(errStream)
+		for {
+			select {
+			case data, ok := <-dataStream:
+				if !ok {
+					return
+				}
+				if err := q.Send(ctx, data); err != nil {
+					errStream <- err
+					return
+				}
+			case <-ctx.Done():
+				errStream <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return dataStream, errStream
+}
+
+// Ack marks the message as processed, it will not be delivered again.
+func (m *Message) Ack() error {
+	q := m.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.inFlight[m.ID]
+	if !ok {
+		return fmt.Errorf("ack %v: not in flight", m.ID)
+	}
+	if q.closed {
+		return ErrClosed
+	}
+	if err := q.write(record{typ: recordAck, id: m.ID}); err != nil {
+		return err
+	}
+	delete(q.inFlight, m.ID)
+	it.seg.drop(it)
+	return q.compact()
+}
+
+// Nack puts the message back at the head of the queue.
+func (m *Message) Nack() error {
+	q := m.q
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.inFlight[m.ID]
+	if !ok {
+		return fmt.Errorf("nack %v: not in flight", m.ID)
+	}
+	delete(q.inFlight, m.ID)
+	q.ready = append([]*item{it}, q.ready...)
+	close(q.notify)
+	q.notify = make(chan struct{})
+	return nil
+}
+
+// Len reports the number of messages not acked yet, in flight or not.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.ready) + len(q.inFlight)
+}
+
+// Close closes the queue. Messages in flight are delivered again after the
+// next Open.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.notify)
+	return q.active.Close()
+}
+
+// write appends r to the active segment. If the write fails, whatever part
+// of r made it to the file is cut off again: recovery stops at a torn record,
+// so a record appended after one would be lost.
+func (q *Queue) write(r record) error {
+	if q.torn {
+		if err := q.active.Truncate(q.activeSize); err != nil {
+			return err
+		}
+		q.torn = false
+	}
+	buf := encodeRecord(r)
+	_, err := q.active.Write(buf)
+	if err == nil && q.syncWrites {
+		err = q.active.Sync()
+	}
+	if err != nil {
+		if q.active.Truncate(q.activeSize) != nil {
+			// Try again before the next write.
+			q.torn = true
+		}
+		return err
+	}
+	q.activeSize += int64(len(buf))
+	q.segments[len(q.segments)-1].size = q.activeSize
+	return nil
+}
+
+// roll starts a new active segment.
+func (q *Queue) roll() error {
+	var seq uint64
+	if n := len(q.segments); n > 0 {
+		seq = q.segments[n-1].seq + 1
+	}
+	seg := &segment{seq: seq, path: segmentPath(q.dir, seq)}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if q.active != nil {
+		// Messages copied forward must be on disk before their old segment
+		// goes.
+		if err := q.active.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+		if err := q.active.Close(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	q.active, q.activeSize = f, 0
+	q.segments = append(q.segments, seg)
+	return nil
+}
+
+// compact deletes the fully acked segments at the head of the log. Only the
+// head is ever deleted: a later segment may hold acks of messages in an
+// earlier one, and must be kept until that one goes. So that a message left
+// unacked does not keep every later segment around, the live messages of
+// the head are copied to the active segment once the log holds more than
+// twice as many bytes as the live messages need.
+func (q *Queue) compact() error {
+	// Segments created by copying are not visited again.
+	for n := len(q.segments) - 1; n > 0; n-- {
+		head := q.segments[0]
+		if head.live > 0 {
+			if !q.wasteful() {
+				return nil
+			}
+			if err := q.copyForward(head); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(head.path); err != nil {
+			return err
+		}
+		q.segments = q.segments[1:]
+	}
+	return nil
+}
+
+func (q *Queue) wasteful() bool {
+	var size, liveBytes int64
+	for _, seg := range q.segments {
+		size += seg.size
+		liveBytes += seg.liveBytes
+	}
+	return size > 2*liveBytes+2*q.segmentSize
+}
+
+// copyForward appends the live messages of seg to the active segment again,
+// under the same IDs, and moves them there.
+func (q *Queue) copyForward(seg *segment) error {
+	var items []*item
+	for _, it := range q.ready {
+		if it.seg == seg {
+			items = append(items, it)
+		}
+	}
+	for _, it := range q.inFlight {
+		if it.seg == seg {
+			items = append(items, it)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].id < items[j].id })
+
+	for _, it := range items {
+		if q.activeSize >= q.segmentSize {
+			if err := q.roll(); err != nil {
+				return err
+			}
+		}
+		if err := q.write(record{typ: recordEnqueue, id: it.id, payload: it.data}); err != nil {
+			return err
+		}
+		seg.drop(it)
+		q.segments[len(q.segments)-1].add(it)
+	}
+	// The copies must be on disk before seg goes.
+	if q.syncWrites {
+		return nil
+	}
+	return q.active.Sync()
+}
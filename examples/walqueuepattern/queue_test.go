@@ -0,0 +1,241 @@
+package walqueuepattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func receive(t *testing.T, q *Queue) *Message {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	msg, err := q.Receive(ctx)
+	assert.NoError(t, err)
+	return msg
+}
+
+func TestQueue_AckNack(t *testing.T) {
+	q, err := Open(t.TempDir())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, q.Send(context.TODO(), []byte(fmt.Sprint(i))))
+	}
+	first := receive(t, q)
+	assert.Equal(t, "0", string(first.Data))
+	assert.NoError(t, first.Nack())
+	assert.Error(t, first.Ack())
+
+	// Nacked messages come back first.
+	first = receive(t, q)
+	assert.Equal(t, "0", string(first.Data))
+	assert.NoError(t, first.Ack())
+	assert.Equal(t, "1", string(receive(t, q).Data))
+	assert.Equal(t, 2, q.Len())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	receive(t, q)
+	_, err = q.Receive(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestQueue_Recover(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		q.Send(context.TODO(), []byte(fmt.Sprint(i)))
+	}
+	receive(t, q).Ack()
+	receive(t, q) // in flight when the process "crashes"
+	assert.NoError(t, q.Close())
+
+	q, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, q.Len())
+	for i := 1; i < 5; i++ {
+		msg := receive(t, q)
+		assert.Equal(t, fmt.Sprint(i), string(msg.Data))
+		msg.Ack()
+	}
+	// New IDs do not collide with the recovered ones.
+	q.Send(context.TODO(), []byte("5"))
+	assert.Equal(t, uint64(5), receive(t, q).ID)
+	q.Close()
+}
+
+func TestQueue_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	assert.NoError(t, err)
+	q.Send(context.TODO(), []byte("kept"))
+	q.Close()
+
+	// Simulate a crash in the middle of the next write.
+	segments, _ := listSegments(dir)
+	last := segments[len(segments)-1].path
+	f, _ := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write(encodeRecord(record{typ: recordEnqueue, id: 1, payload: []byte("torn")})[:10])
+	f.Close()
+
+	q, err = Open(dir)
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 1, q.Len())
+	assert.Equal(t, "kept", string(receive(t, q).Data))
+}
+
+// failingFile writes only part of the next record and fails.
+type failingFile struct {
+	segmentFile
+	fail bool
+}
+
+func (f *failingFile) Write(b []byte) (int, error) {
+	if f.fail {
+		f.fail = false
+		n, _ := f.segmentFile.Write(b[:len(b)/2])
+		return n, errors.New("no space left on device")
+	}
+	return f.segmentFile.Write(b)
+}
+
+func TestQueue_FailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, q.Send(context.TODO(), []byte("0")))
+	q.active = &failingFile{segmentFile: q.active, fail: true}
+	assert.Error(t, q.Send(context.TODO(), []byte("lost")))
+	assert.NoError(t, q.Send(context.TODO(), []byte("1")))
+	assert.NoError(t, q.Close())
+
+	// The records written after the failed one are recovered.
+	q, err = Open(dir)
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 2, q.Len())
+	assert.Equal(t, "0", string(receive(t, q).Data))
+	assert.Equal(t, "1", string(receive(t, q).Data))
+}
+
+func TestQueue_Compaction(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, WithSegmentSize(64), WithSyncWrites())
+	assert.NoError(t, err)
+	defer q.Close()
+
+	const N = 20
+	for i := 0; i < N; i++ {
+		q.Send(context.TODO(), []byte("some payload"))
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Greater(t, len(files), 5)
+
+	for i := 0; i < N; i++ {
+		assert.NoError(t, receive(t, q).Ack())
+	}
+	files, _ = filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Len(t, files, 1)
+}
+
+func TestQueue_CompactionWithPinnedMessage(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, WithSegmentSize(64))
+	assert.NoError(t, err)
+
+	assert.NoError(t, q.Send(context.TODO(), []byte("pinned")))
+	pinned := receive(t, q) // never acked
+	const N = 200
+	for i := 0; i < N; i++ {
+		assert.NoError(t, q.Send(context.TODO(), []byte("some payload")))
+		assert.NoError(t, receive(t, q).Ack())
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Less(t, len(files), 10)
+	assert.NoError(t, q.Close())
+
+	q, err = Open(dir, WithSegmentSize(64))
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 1, q.Len())
+	msg := receive(t, q)
+	assert.Equal(t, pinned.ID, msg.ID)
+	assert.Equal(t, "pinned", string(msg.Data))
+	assert.NoError(t, msg.Ack())
+	files, _ = filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Len(t, files, 1)
+}
+
+func TestQueue_RecoverCopiedMessage(t *testing.T) {
+	// A crash after a message was copied forward, before its old segment
+	// was deleted, leaves it in the log twice.
+	dir := t.TempDir()
+	enqueue := encodeRecord(record{typ: recordEnqueue, id: 0, payload: []byte("copied")})
+	assert.NoError(t, os.WriteFile(segmentPath(dir, 0), enqueue, 0o644))
+	assert.NoError(t, os.WriteFile(segmentPath(dir, 1), enqueue, 0o644))
+
+	q, err := Open(dir)
+	assert.NoError(t, err)
+	defer q.Close()
+	assert.Equal(t, 1, q.Len())
+	assert.NoError(t, receive(t, q).Ack())
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	assert.Len(t, files, 1)
+}
+
+func TestQueue_Sender(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir)
+	assert.NoError(t, err)
+
+	dataStream, errStream := q.Sender(context.TODO())
+	for i := 0; i < 10; i++ {
+		dataStream <- []byte(fmt.Sprint(i))
+	}
+	close(dataStream)
+	assert.NoError(t, <-errStream)
+	assert.NoError(t, q.Close())
+
+	// What was sent survives a restart.
+	q, err = Open(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, q.Len())
+	assert.NoError(t, q.Close())
+
+	dataStream, errStream = q.Sender(context.TODO())
+	dataStream <- []byte("closed")
+	assert.ErrorIs(t, <-errStream, ErrClosed)
+}
+
+func TestQueue_Messages(t *testing.T) {
+	q, err := Open(t.TempDir())
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			q.Send(ctx, []byte(fmt.Sprint(i)))
+		}
+	}()
+	i := 0
+	for msg := range q.Messages(ctx) {
+		assert.Equal(t, fmt.Sprint(i), string(msg.Data))
+		msg.Ack()
+		i++
+		if i == 10 {
+			q.Close()
+		}
+	}
+	assert.Equal(t, 10, i)
+}
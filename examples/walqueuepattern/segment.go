@@ -0,0 +1,140 @@
+package walqueuepattern
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	recordEnqueue byte = iota + 1
+	recordAck
+)
+
+// A record on disk is:
+//
+//	length  uint32 // of type, id and payload
+//	crc     uint32 // Castagnoli, of type, id and payload
+//	type    byte
+//	id      uint64
+//	payload []byte
+const headerSize = 4 + 4
+
+var (
+	crcTable      = crc32.MakeTable(crc32.Castagnoli)
+	errCorrupted  = errors.New("corrupted record")
+	segmentSuffix = ".seg"
+)
+
+type record struct {
+	typ     byte
+	id      uint64
+	payload []byte
+}
+
+// segment is one file of the log. Segments are only ever appended to, and
+// deleted as a whole once every message they hold is acked or copied to a
+// later segment.
+type segment struct {
+	seq       uint64
+	path      string
+	size      int64
+	live      int   // enqueued and not yet acked
+	liveBytes int64 // size of the records of the live messages
+}
+
+// enqueueSize is the size on disk of the enqueue record of data.
+func enqueueSize(data []byte) int64 {
+	return int64(headerSize + 1 + 8 + len(data))
+}
+
+func segmentPath(dir string, seq uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d%s", seq, segmentSuffix))
+}
+
+func listSegments(dir string) ([]*segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []*segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, &segment{seq: seq, path: filepath.Join(dir, name)})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].seq < segments[j].seq })
+	return segments, nil
+}
+
+func encodeRecord(r record) []byte {
+	buf := make([]byte, headerSize+1+8+len(r.payload))
+	body := buf[headerSize:]
+	body[0] = r.typ
+	binary.BigEndian.PutUint64(body[1:], r.id)
+	copy(body[9:], r.payload)
+	binary.BigEndian.PutUint32(buf[0:], uint32(len(body)))
+	binary.BigEndian.PutUint32(buf[4:], crc32.Checksum(body, crcTable))
+	return buf
+}
+
+// readRecords calls fn for every record of the file. It returns the offset
+// after the last valid record and errCorrupted if the file goes on with a
+// torn or damaged record.
+func readRecords(path string, fn func(record)) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	header := make([]byte, headerSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return offset, nil
+			}
+			return offset, errCorrupted
+		}
+		length := binary.BigEndian.Uint32(header[0:])
+		if length < 9 || length > 1<<30 {
+			return offset, errCorrupted
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return offset, errCorrupted
+		}
+		if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+			return offset, errCorrupted
+		}
+		fn(record{typ: body[0], id: binary.BigEndian.Uint64(body[1:]), payload: body[9:]})
+		offset += int64(headerSize + length)
+	}
+}
+
+func (s *segment) add(it *item) {
+	it.seg = s
+	s.live++
+	s.liveBytes += enqueueSize(it.data)
+}
+
+func (s *segment) drop(it *item) {
+	s.live--
+	s.liveBytes -= enqueueSize(it.data)
+}
@@ -0,0 +1,141 @@
+package watchdogpattern
+
+import (
+	"bytes"
+	"context"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// labelKey is the pprof label that marks the goroutines of a watched loop.
+const labelKey = "watchdog"
+
+// Stall describes a loop iteration that ran past the threshold.
+type Stall struct {
+	Name  string
+	Since time.Time     // when the stuck iteration began
+	For   time.Duration // how long it had been running when detected
+	// Stacks is the goroutine profile (pprof debug=1 format) restricted to
+	// the goroutines labeled with Name. It is the full profile if none are.
+	Stacks []byte
+}
+
+// Watchdog reports select loops that get stuck inside an iteration. A loop
+// blocked in its select waiting for work is idle, not stuck: only the time
+// between Begin and End counts.
+//
+// The methods of a nil *Watchdog do nothing, so loops can call them
+// unconditionally.
+type Watchdog struct {
+	clock     clockpattern.Clock
+	name      string
+	threshold time.Duration
+	hook      func(Stall)
+
+	mu       sync.Mutex
+	busy     bool
+	since    time.Time
+	seq      uint64 // iterations begun
+	reported uint64 // last iteration passed to hook
+}
+
+// NewWatchdog returns a watchdog for the loop called name. hook is called at
+// most once per stuck iteration, from the Run goroutine.
+func NewWatchdog(clock clockpattern.Clock, name string, threshold time.Duration, hook func(Stall)) *Watchdog {
+	return &Watchdog{
+		clock:     clock,
+		name:      name,
+		threshold: threshold,
+		hook:      hook,
+	}
+}
+
+// Label labels the calling goroutine, and the goroutines it starts from now
+// on, so that they show up in Stall.Stacks.
+func (w *Watchdog) Label(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	pprof.SetGoroutineLabels(pprof.WithLabels(ctx, pprof.Labels(labelKey, w.name)))
+}
+
+// Begin marks the start of an iteration, right after the select fired.
+func (w *Watchdog) Begin() {
+	if w == nil {
+		return
+	}
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.busy = true
+	w.since = now
+	w.seq++
+}
+
+// End marks the end of an iteration.
+func (w *Watchdog) End() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.busy = false
+}
+
+// Run checks the loop every quarter threshold until ctx is done.
+func (w *Watchdog) Run(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	go func() {
+		ticker := w.clock.NewTicker(w.threshold / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+				if stall, ok := w.check(); ok {
+					stall.Stacks = stacks(w.name)
+					w.hook(stall)
+				}
+			}
+		}
+	}()
+}
+
+func (w *Watchdog) check() (Stall, bool) {
+	now := w.clock.Now()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.busy || w.reported == w.seq || now.Sub(w.since) < w.threshold {
+		return Stall{}, false
+	}
+	w.reported = w.seq
+	return Stall{Name: w.name, Since: w.since, For: now.Sub(w.since)}, true
+}
+
+// stacks returns the goroutine profile entries labeled with name.
+func stacks(name string) []byte {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil
+	}
+	// debug=1 prints a header, then one blank-line separated entry per
+	// distinct stack, each with a "# labels: {...}" line when labeled.
+	label := []byte(`"` + labelKey + `":"` + name + `"`)
+	entries := bytes.Split(buf.Bytes(), []byte("\n\n"))
+	var out bytes.Buffer
+	for _, entry := range entries[1:] {
+		if bytes.Contains(entry, label) {
+			out.Write(entry)
+			out.WriteString("\n\n")
+		}
+	}
+	if out.Len() == 0 {
+		return buf.Bytes()
+	}
+	return out.Bytes()
+}
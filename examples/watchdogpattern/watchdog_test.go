@@ -0,0 +1,94 @@
+package watchdogpattern
+
+import (
+	"context"
+	"rhzx3519/go-concurrency/examples/clockpattern"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const threshold = time.Second
+
+// loop is a select loop whose handler blocks on result, like an actor
+// replying to a caller that has gone away.
+func loop(ctx context.Context, w *Watchdog, requests <-chan chan int) {
+	go func() {
+		w.Label(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case result := <-requests:
+				w.Begin()
+				select {
+				case result <- 1:
+				case <-ctx.Done():
+				}
+				w.End()
+			}
+		}
+	}()
+}
+
+func TestWatchdog_Stuck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	clock := clockpattern.NewFake(time.Now())
+
+	var mu sync.Mutex
+	var stalls []Stall
+	w := NewWatchdog(clock, "counter", threshold, func(stall Stall) {
+		mu.Lock()
+		defer mu.Unlock()
+		stalls = append(stalls, stall)
+	})
+	w.Run(ctx)
+	requests := make(chan chan int)
+	loop(ctx, w, requests)
+
+	// Served requests and idle time are not stalls.
+	result := make(chan int)
+	requests <- result
+	assert.Equal(t, 1, <-result)
+	assert.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return !w.busy
+	}, time.Second, time.Millisecond)
+	for i := 0; i < 8; i++ {
+		clock.Advance(threshold / 4)
+	}
+
+	// Nobody reads this one.
+	requests <- make(chan int)
+	assert.Eventually(t, func() bool {
+		clock.Advance(threshold / 4)
+		mu.Lock()
+		defer mu.Unlock()
+		return len(stalls) > 0
+	}, time.Second, time.Millisecond)
+
+	// Reported once per stuck iteration.
+	for i := 0; i < 8; i++ {
+		clock.Advance(threshold / 4)
+	}
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, stalls, 1)
+	assert.Equal(t, "counter", stalls[0].Name)
+	assert.GreaterOrEqual(t, stalls[0].For, threshold)
+	assert.Contains(t, string(stalls[0].Stacks), `"watchdog":"counter"`)
+	assert.Contains(t, string(stalls[0].Stacks), "watchdogpattern.loop")
+}
+
+func TestWatchdog_Nil(t *testing.T) {
+	var w *Watchdog
+	w.Label(context.TODO())
+	w.Begin()
+	w.End()
+	w.Run(context.TODO())
+}
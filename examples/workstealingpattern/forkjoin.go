@@ -0,0 +1,77 @@
+package workstealingpattern
+
+import (
+	"context"
+)
+
+// Forked is the pending result of a task started with Fork.
+type Forked[T any] struct {
+	pool *Pool
+	done chan struct{}
+	val  T
+	err  error
+}
+
+// Fork schedules fn on the pool and returns a handle to join it. Forked from
+// inside a task, fn lands on the current worker's deque, where it is either
+// run by the worker itself when it joins or stolen by an idle one.
+//
+// If ctx is done before fn runs, fn is skipped and Join returns ctx.Err(). If
+// fn panics, Join returns the panic as a *errgrouppattern.PanicError.
+func Fork[T any](ctx context.Context, p *Pool, fn func(ctx context.Context) T) (*Forked[T], error) {
+	f := &Forked[T]{pool: p, done: make(chan struct{})}
+	err := p.submit(task{
+		ctx: ctx,
+		fn: func(ctx context.Context) {
+			defer close(f.done)
+			f.err = call(ctx, func(ctx context.Context) {
+				f.val = fn(ctx)
+			})
+		},
+		skip: func(err error) {
+			f.err = err
+			close(f.done)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Join waits for the forked task. A worker does not block while joining: it
+// keeps running queued tasks, so recursive fork/join cannot deadlock the
+// pool even with a single worker.
+func (f *Forked[T]) Join(ctx context.Context) (T, error) {
+	w := f.pool.current(ctx)
+	for {
+		var wake <-chan struct{}
+		if w != nil && !isDone(f.done) {
+			if w.runOne() {
+				continue
+			}
+			// Wake up to help with tasks pushed while waiting.
+			wake = f.pool.waiter()
+			if w.runOne() {
+				continue
+			}
+		}
+		select {
+		case <-f.done:
+			return f.val, f.err
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
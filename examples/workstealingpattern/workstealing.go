@@ -0,0 +1,276 @@
+package workstealingpattern
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"rhzx3519/go-concurrency/examples/errgrouppattern"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrClosed = errors.New("pool closed")
+
+type Task func(ctx context.Context)
+
+// Pool is a work-stealing executor. Every worker owns a deque: it pushes and
+// pops its own tasks at the bottom, and when it runs dry it steals from the
+// top of a random other worker. Unlike a single shared channel, workers only
+// contend when one of them is stealing.
+type Pool struct {
+	workers []*worker
+	next    atomic.Uint64 // round robin for tasks submitted from outside
+	onPanic func(err error)
+	logger  *slog.Logger
+	// wakeMu guards wake and waiting. wake is closed and replaced by signal,
+	// but only when someone is waiting on it.
+	wakeMu  sync.Mutex
+	wake    chan struct{}
+	waiting bool
+	// mu orders Submit from outside against Close: a task is either pushed
+	// before closed is set, and run, or refused.
+	mu     sync.RWMutex
+	closed atomic.Bool
+	wg     sync.WaitGroup
+}
+
+type worker struct {
+	pool  *Pool
+	mu    sync.Mutex
+	tasks []task
+}
+
+type task struct {
+	ctx context.Context
+	fn  Task
+	// skip, if not nil, is called instead of fn when ctx is done.
+	skip func(err error)
+}
+
+type workerKey struct{}
+
+type Option func(*Pool)
+
+// WithPanicHandler passes the *errgrouppattern.PanicError of every task that
+// panics to fn, instead of logging it. Either way the worker carries on with
+// the next task.
+func WithPanicHandler(fn func(err error)) Option {
+	return func(p *Pool) {
+		p.onPanic = fn
+	}
+}
+
+// WithLogger logs the panics of tasks at error level. By default nothing is
+// logged.
+func WithLogger(logger *slog.Logger) Option {
+	return func(p *Pool) {
+		p.logger = logger
+	}
+}
+
+// NewPool starts n workers, runtime.GOMAXPROCS(0) if n is not positive.
+func NewPool(n int, opts ...Option) *Pool {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	p := &Pool{
+		workers: make([]*worker, n),
+		wake:    make(chan struct{}),
+		logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for i := range p.workers {
+		p.workers[i] = &worker{pool: p}
+	}
+	p.wg.Add(n)
+	for _, w := range p.workers {
+		go w.run()
+	}
+	return p
+}
+
+// Submit schedules fn. Called from inside a task of the pool, fn goes to the
+// bottom of the current worker's deque, otherwise to the workers in turn. fn
+// is skipped if ctx is done by the time it would run.
+func (p *Pool) Submit(ctx context.Context, fn Task) error {
+	return p.submit(task{ctx: ctx, fn: fn})
+}
+
+func (p *Pool) submit(t task) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	// The current worker cannot exit while it is running this task, and it
+	// runs its own deque dry before it does.
+	if w := p.current(t.ctx); w != nil {
+		w.push(t)
+		p.signal()
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed.Load() {
+		return ErrClosed
+	}
+	i := p.next.Add(1) % uint64(len(p.workers))
+	p.workers[i].push(t)
+	p.signal()
+	return nil
+}
+
+// Close stops accepting tasks from outside the pool and waits for the queued
+// ones to run.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed.Store(true)
+	p.mu.Unlock()
+	p.signal()
+	p.wg.Wait()
+}
+
+// current returns the worker running the task that owns ctx, if any.
+func (p *Pool) current(ctx context.Context) *worker {
+	w, _ := ctx.Value(workerKey{}).(*worker)
+	if w == nil || w.pool != p {
+		return nil
+	}
+	return w
+}
+
+// signal wakes up every idle worker, and the workers joining a task, after
+// a task was pushed or the pool closed.
+func (p *Pool) signal() {
+	p.wakeMu.Lock()
+	if p.waiting {
+		close(p.wake)
+		p.wake = make(chan struct{})
+		p.waiting = false
+	}
+	p.wakeMu.Unlock()
+}
+
+// waiter returns a channel closed by the next signal. The caller must look
+// for tasks once more after getting it: a task pushed earlier may not signal
+// it.
+func (p *Pool) waiter() <-chan struct{} {
+	p.wakeMu.Lock()
+	defer p.wakeMu.Unlock()
+	p.waiting = true
+	return p.wake
+}
+
+func (w *worker) run() {
+	defer w.pool.wg.Done()
+	for {
+		if w.runOne() {
+			continue
+		}
+		wake := w.pool.waiter()
+		if w.runOne() {
+			continue
+		}
+		if w.pool.closed.Load() && w.pool.empty() {
+			return
+		}
+		<-wake
+	}
+}
+
+// runOne runs one task from the worker's own deque or stolen from another
+// worker. It reports whether there was a task to run.
+func (w *worker) runOne() bool {
+	t, ok := w.pop()
+	if !ok {
+		t, ok = w.steal()
+	}
+	if !ok {
+		return false
+	}
+	if err := t.ctx.Err(); err != nil {
+		if t.skip != nil {
+			t.skip(err)
+		}
+		return true
+	}
+	if err := call(context.WithValue(t.ctx, workerKey{}, w), t.fn); err != nil {
+		if w.pool.onPanic != nil {
+			w.pool.onPanic(err)
+		} else {
+			w.pool.logger.ErrorContext(t.ctx, "task panicked", slog.Any("err", err))
+		}
+	}
+	return true
+}
+
+// call runs fn, turning a panic into an error so that it does not take the
+// worker down with it.
+func call(ctx context.Context, fn Task) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &errgrouppattern.PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	fn(ctx)
+	return nil
+}
+
+func (w *worker) push(t task) {
+	w.mu.Lock()
+	w.tasks = append(w.tasks, t)
+	w.mu.Unlock()
+}
+
+// pop takes the newest task, the one most likely to be hot in cache.
+func (w *worker) pop() (task, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := len(w.tasks)
+	if n == 0 {
+		return task{}, false
+	}
+	t := w.tasks[n-1]
+	w.tasks[n-1] = task{}
+	w.tasks = w.tasks[:n-1]
+	return t, true
+}
+
+// steal takes the oldest task of a random victim. In divide and conquer the
+// oldest tasks are the biggest ones, so a thief does not come back soon.
+func (w *worker) steal() (task, bool) {
+	workers := w.pool.workers
+	start := rand.IntN(len(workers))
+	for i := range workers {
+		victim := workers[(start+i)%len(workers)]
+		if victim == w {
+			continue
+		}
+		victim.mu.Lock()
+		if len(victim.tasks) > 0 {
+			t := victim.tasks[0]
+			victim.tasks[0] = task{}
+			victim.tasks = victim.tasks[1:]
+			victim.mu.Unlock()
+			return t, true
+		}
+		victim.mu.Unlock()
+	}
+	return task{}, false
+}
+
+func (p *Pool) empty() bool {
+	for _, w := range p.workers {
+		w.mu.Lock()
+		n := len(w.tasks)
+		w.mu.Unlock()
+		if n > 0 {
+			return false
+		}
+	}
+	return true
+}
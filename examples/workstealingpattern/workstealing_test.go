@@ -0,0 +1,193 @@
+package workstealingpattern
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"rhzx3519/go-concurrency/examples/errgrouppattern"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_Submit(t *testing.T) {
+	pool := NewPool(4)
+	var count atomic.Int32
+	var wg sync.WaitGroup
+	const N = 1000
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		assert.NoError(t, pool.Submit(context.TODO(), func(context.Context) {
+			defer wg.Done()
+			count.Add(1)
+		}))
+	}
+	wg.Wait()
+	pool.Close()
+
+	assert.Equal(t, int32(N), count.Load())
+	assert.ErrorIs(t, pool.Submit(context.TODO(), func(context.Context) {}), ErrClosed)
+}
+
+func TestPool_Stealing(t *testing.T) {
+	pool := NewPool(4)
+	defer pool.Close()
+
+	// Every task is pushed on the deque of the worker running the first one,
+	// so the other workers only get them by stealing.
+	var mu sync.Mutex
+	ran := make(map[*worker]bool)
+	var wg sync.WaitGroup
+	const N = 100
+	wg.Add(N)
+	pool.Submit(context.TODO(), func(ctx context.Context) {
+		for i := 0; i < N; i++ {
+			pool.Submit(ctx, func(ctx context.Context) {
+				defer wg.Done()
+				mu.Lock()
+				ran[pool.current(ctx)] = true
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+			})
+		}
+	})
+	wg.Wait()
+	assert.Greater(t, len(ran), 1)
+}
+
+func TestPool_SkipsCanceledTasks(t *testing.T) {
+	pool := NewPool(1)
+	ctx, cancel := context.WithCancel(context.TODO())
+	started, release := make(chan struct{}), make(chan struct{})
+	pool.Submit(context.TODO(), func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var ran atomic.Bool
+	pool.Submit(ctx, func(context.Context) { ran.Store(true) })
+	cancel()
+	close(release)
+	pool.Close()
+	assert.False(t, ran.Load())
+}
+
+func TestPool_SubmitRacingClose(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		pool := NewPool(2)
+		var accepted, ran atomic.Int32
+		var wg sync.WaitGroup
+		for j := 0; j < 4; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for pool.Submit(context.TODO(), func(context.Context) { ran.Add(1) }) == nil {
+					accepted.Add(1)
+				}
+			}()
+		}
+		pool.Close()
+		wg.Wait()
+		// Every accepted task ran.
+		assert.Equal(t, accepted.Load(), ran.Load())
+	}
+}
+
+func TestPool_Panic(t *testing.T) {
+	panicked := make(chan error, 1)
+	pool := NewPool(1, WithPanicHandler(func(err error) { panicked <- err }))
+	pool.Submit(context.TODO(), func(context.Context) { panic("boom") })
+	var panicErr *errgrouppattern.PanicError
+	assert.ErrorAs(t, <-panicked, &panicErr)
+	assert.Equal(t, "boom", panicErr.Value)
+
+	// The worker survived, and a forked task reports its panic on Join.
+	boom := errors.New("boom")
+	f, err := Fork(context.TODO(), pool, func(context.Context) int { panic(boom) })
+	assert.NoError(t, err)
+	_, err = f.Join(context.TODO())
+	assert.ErrorIs(t, err, boom)
+	pool.Close()
+}
+
+func TestPool_LogsPanics(t *testing.T) {
+	var buf bytes.Buffer
+	pool := NewPool(1, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	pool.Submit(context.TODO(), func(context.Context) { panic("boom") })
+	pool.Close()
+	assert.Contains(t, buf.String(), "task panicked")
+	assert.Contains(t, buf.String(), "boom")
+}
+
+func TestPool_WakesIdleWorkers(t *testing.T) {
+	// Idle workers block until a task comes, none may miss it.
+	pool := NewPool(4)
+	defer pool.Close()
+	for i := 0; i < 1000; i++ {
+		done := make(chan struct{})
+		pool.Submit(context.TODO(), func(context.Context) { close(done) })
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("task %v not run", i)
+		}
+	}
+}
+
+func TestFork_Skipped(t *testing.T) {
+	pool := NewPool(1)
+	defer pool.Close()
+	started, release := make(chan struct{}), make(chan struct{})
+	pool.Submit(context.TODO(), func(context.Context) {
+		close(started)
+		<-release
+	})
+	<-started
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	f, err := Fork(ctx, pool, func(context.Context) int { return 1 })
+	assert.NoError(t, err)
+	cancel()
+	close(release)
+	// Joined with another ctx, the skipped task still reports back.
+	_, err = f.Join(context.TODO())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func fib(ctx context.Context, pool *Pool, n int) int {
+	if n < 2 {
+		return n
+	}
+	f, err := Fork(ctx, pool, func(ctx context.Context) int {
+		return fib(ctx, pool, n-1)
+	})
+	if err != nil {
+		panic(err)
+	}
+	b := fib(ctx, pool, n-2)
+	a, err := f.Join(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return a + b
+}
+
+func TestFork_Recursive(t *testing.T) {
+	// A single worker must not deadlock on its own forks.
+	for _, workers := range []int{1, 4} {
+		pool := NewPool(workers)
+		f, err := Fork(context.TODO(), pool, func(ctx context.Context) int {
+			return fib(ctx, pool, 20)
+		})
+		assert.NoError(t, err)
+		v, err := f.Join(context.TODO())
+		assert.NoError(t, err)
+		assert.Equal(t, 6765, v)
+		pool.Close()
+	}
+}